@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// BlockingPoolOptions 阻塞指令(BLPOP、BRPOP、BRPOPLPUSH、BLMOVE、BZPOPMIN、BZPOPMAX、XREAD/XREADGROUP BLOCK)專用的連接池，
+// PoolSize 大於0時啟用，阻塞指令不再佔用一般指令的連接
+type BlockingPoolOptions struct {
+	PoolSize     int           // 連接池大小，即同時執行的阻塞指令上限
+	MinIdleConns int           // 最小空閒連接數
+	PoolTimeout  time.Duration // 等待連接池的逾時，預設與主連接池相同
+	ReadTimeout  time.Duration // socket 讀取逾時，會再加上指令的阻塞時間，預設與主連接池相同
+	IdleTimeout  time.Duration // 空閑連接的超時時間，預設與主連接池相同
+}
+
+// BlockingPoolMetrics Metrics 可另外實作的介面，啟用阻塞指令連接池時一併帶入其狀態
+type BlockingPoolMetrics interface {
+	ObserveBlockingPoolStats(stats PoolStats)
+}
+
+// BlockingPoolStats 取得阻塞指令連接池的狀態，未啟用時為零值
+func (c *Cacher) BlockingPoolStats() PoolStats {
+	if c.blocking == nil {
+		return PoolStats{}
+	}
+	return newPoolStats(c.blocking.PoolStats())
+}
+
+// newBlockingClient 以主庫的設定建立阻塞指令專用的連接池
+func newBlockingClient(opts BlockingPoolOptions, primary *redis.Options, lc *lifecycle) *redis.Client {
+	redisOption := *primary
+	redisOption.PoolSize = opts.PoolSize
+	redisOption.MinIdleConns = opts.MinIdleConns
+	if opts.PoolTimeout > 0 {
+		redisOption.PoolTimeout = opts.PoolTimeout
+	}
+	if opts.ReadTimeout > 0 {
+		redisOption.ReadTimeout = opts.ReadTimeout
+	}
+	if opts.IdleTimeout > 0 {
+		redisOption.IdleTimeout = opts.IdleTimeout
+	}
+	client := redis.NewClient(&redisOption)
+	client.AddHook(lifecycleHook{lc})
+	return client
+}
+
+// isBlockingCommand 是否為會佔住連接的阻塞指令，WAIT 需要與寫入使用同一個連接，不算在內
+func isBlockingCommand(args []interface{}) bool {
+	if _, blocking := blockDuration(args); !blocking {
+		return false
+	}
+	return !strings.EqualFold(formatArg(args[0]), "WAIT")
+}
+
+// commandPool 選擇執行指令的連接池：阻塞指令使用專用連接池，唯讀指令使用從庫，其餘使用主庫
+func (c *Cacher) commandPool(args []interface{}) (*redis.Client, *replicaNode) {
+	if c.blocking != nil && isBlockingCommand(args) {
+		return c.blocking, nil
+	}
+	if replica := c.replicaFor(args); replica != nil {
+		return replica.client, replica
+	}
+	return c.pool, nil
+}
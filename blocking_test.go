@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/metric"
+)
+
+func TestCacher_BlockingPool(t *testing.T) {
+	c, _ := newTestCacher(t, func(o *Options) {
+		o.PoolSize = 1
+		o.Timeouts = TimeoutOptions{Pool: 100 * time.Millisecond}
+		o.Blocking = BlockingPoolOptions{PoolSize: 2}
+	})
+
+	done := make(chan *Cmd, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- c.BLPop("blocking-list", 1) }()
+	}
+	if !waitFor(time.Second, func() bool { return c.BlockingPoolStats().TotalConns == 2 }) {
+		t.Fatalf("BlockingPoolStats() = %+v, want 2 conns", c.BlockingPoolStats())
+	}
+	// 阻塞指令不佔用主連接池
+	if err := c.Set("blocking-T1", "a", 0).Err; err != nil {
+		t.Errorf("Set() while blocked error = %v", err)
+	}
+	c.RPush("blocking-list", "x", "y")
+	for i := 0; i < 2; i++ {
+		if cmd := <-done; cmd.Err != nil {
+			t.Errorf("BLPop() error = %v", cmd.Err)
+		}
+	}
+	if stats := c.PoolStats(); stats.TotalConns != 1 {
+		t.Errorf("PoolStats() = %+v, want 1 conn", stats)
+	}
+}
+
+func TestCommandPool(t *testing.T) {
+	c := &Cacher{pool: redis.NewClient(&redis.Options{}), blocking: redis.NewClient(&redis.Options{})}
+	defer c.closePools()
+	tests := []struct {
+		args []interface{}
+		want bool
+	}{
+		{[]interface{}{"BLPOP", "k", 1}, true},
+		{[]interface{}{"BZPOPMIN", "z", 0}, true},
+		{[]interface{}{"XREAD", "BLOCK", 10, "STREAMS", "s", "$"}, true},
+		{[]interface{}{"XREAD", "STREAMS", "s", "$"}, false},
+		{[]interface{}{"WAIT", 1, 100}, false},
+		{[]interface{}{"GET", "k"}, false},
+	}
+	for _, tt := range tests {
+		client, _ := c.commandPool(tt.args)
+		if got := client == c.blocking; got != tt.want {
+			t.Errorf("commandPool(%v) uses blocking pool = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestBlockingPoolMetrics(t *testing.T) {
+	p := NewPrometheusMetrics("", nil)
+	p.ObservePoolStats(PoolStats{TotalConns: 3})
+	p.ObserveBlockingPoolStats(PoolStats{TotalConns: 5})
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	for _, want := range []string{"redis_pool_total_conns 3", `redis_pool_total_conns{pool="blocking"} 5`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q\n%s", want, buf.String())
+		}
+	}
+
+	meter := &recordMeter{values: make(map[string]float64)}
+	m, err := NewOTelMetrics(metric.WrapMeterImpl(meter, "test"))
+	if err != nil {
+		t.Fatalf("NewOTelMetrics() error = %v", err)
+	}
+	m.ObservePoolStats(PoolStats{TotalConns: 3})
+	m.ObserveBlockingPoolStats(PoolStats{TotalConns: 5})
+	meter.collect()
+	if meter.values["redis.pool.total_conns"] != 3 || meter.values["redis.pool.total_conns,pool=blocking"] != 5 {
+		t.Errorf("values = %v", meter.values)
+	}
+}
@@ -0,0 +1,352 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrCircuitOpen 斷路器開啟中，指令沒有送出
+var ErrCircuitOpen = errors.New("breaker: circuit is open")
+
+// CircuitState 斷路器狀態
+type CircuitState int
+
+const (
+	// CircuitClosed 正常送出指令並統計錯誤率
+	CircuitClosed CircuitState = iota
+	// CircuitOpen 直接返回 ErrCircuitOpen，冷卻時間後進入 CircuitHalfOpen
+	CircuitOpen
+	// CircuitHalfOpen 放行少量試探指令，成功則關閉，失敗則重新開啟
+	CircuitHalfOpen
+)
+
+// String 狀態名稱
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "CircuitState(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+// CommandClass 指令類別
+type CommandClass int
+
+const (
+	// ClassWrite 寫入指令，未列為讀取的指令(包含腳本)都視為寫入
+	ClassWrite CommandClass = iota
+	// ClassRead 唯讀指令
+	ClassRead
+)
+
+// String 類別名稱
+func (c CommandClass) String() string {
+	if c == ClassRead {
+		return "read"
+	}
+	return "write"
+}
+
+// readCommands 唯讀指令
+var readCommands = map[string]bool{
+	"GET": true, "MGET": true, "GETRANGE": true, "STRLEN": true, "EXISTS": true, "TTL": true, "PTTL": true,
+	"TYPE": true, "KEYS": true, "SCAN": true, "RANDOMKEY": true, "DBSIZE": true, "PING": true, "ECHO": true,
+	"HGET": true, "HMGET": true, "HGETALL": true, "HEXISTS": true, "HLEN": true, "HKEYS": true, "HVALS": true,
+	"HSTRLEN": true, "HSCAN": true,
+	"LRANGE": true, "LLEN": true, "LINDEX": true, "LPOS": true,
+	"SMEMBERS": true, "SISMEMBER": true, "SMISMEMBER": true, "SCARD": true, "SRANDMEMBER": true, "SSCAN": true,
+	"SDIFF": true, "SINTER": true, "SUNION": true,
+	"ZRANGE": true, "ZREVRANGE": true, "ZRANGEBYSCORE": true, "ZREVRANGEBYSCORE": true, "ZRANGEBYLEX": true,
+	"ZREVRANGEBYLEX": true, "ZSCORE": true, "ZMSCORE": true, "ZCARD": true, "ZCOUNT": true, "ZLEXCOUNT": true,
+	"ZRANK": true, "ZREVRANK": true, "ZSCAN": true,
+	"XRANGE": true, "XREVRANGE": true, "XLEN": true, "XREAD": true, "XINFO": true, "XPENDING": true,
+	"GETBIT": true, "BITCOUNT": true, "BITPOS": true, "PFCOUNT": true,
+	"GEOPOS": true, "GEODIST": true, "GEOHASH": true, "GEORADIUS_RO": true, "GEORADIUSBYMEMBER_RO": true, "GEOSEARCH": true,
+}
+
+// commandClass 依指令名稱判斷類別
+func commandClass(name string) CommandClass {
+	if readCommands[strings.ToUpper(name)] {
+		return ClassRead
+	}
+	return ClassWrite
+}
+
+// BreakerOptions 斷路器設定
+type BreakerOptions struct {
+	Window           time.Duration // 統計錯誤率的時間窗，預設10秒
+	MinRequests      int           // 時間窗內至少要有的指令數才會判斷，預設20
+	ErrorRate        float64       // 錯誤率超過時開啟，預設0.5
+	SlowThreshold    time.Duration // 耗時超過時視為慢指令，0 表示不判斷延遲；阻塞指令(BLPOP、XREAD BLOCK 等)不判斷
+	SlowRate         float64       // 慢指令比例超過時開啟，預設0.5
+	CoolDown         time.Duration // 開啟後經過多久進入半開，預設5秒
+	HalfOpenRequests int           // 半開時放行的試探指令數，全部成功才關閉，預設1
+	PerClass         bool          // 讀取及寫入指令各自使用一個斷路器
+	// OnStateChange 狀態改變時呼叫，未設定 PerClass 時 class 為觸發改變的指令類別
+	OnStateChange func(class CommandClass, from, to CircuitState)
+}
+
+// EnableCircuitBreaker 在 Redis 異常時直接返回 ErrCircuitOpen，避免每個請求都等到逾時。
+// 以 hook 實作，同 AddHook 需在開始執行指令前呼叫。
+func (c *Cacher) EnableCircuitBreaker(opts BreakerOptions) {
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 20
+	}
+	if opts.ErrorRate <= 0 {
+		opts.ErrorRate = 0.5
+	}
+	if opts.SlowRate <= 0 {
+		opts.SlowRate = 0.5
+	}
+	if opts.CoolDown <= 0 {
+		opts.CoolDown = 5 * time.Second
+	}
+	if opts.HalfOpenRequests <= 0 {
+		opts.HalfOpenRequests = 1
+	}
+
+	h := &breakerHook{}
+	h.breakers[ClassWrite] = newCircuitBreaker(opts)
+	h.breakers[ClassRead] = h.breakers[ClassWrite]
+	if opts.PerClass {
+		h.breakers[ClassRead] = newCircuitBreaker(opts)
+	}
+	c.breaker = h
+	c.addRedisHook(h)
+}
+
+// CircuitState 取得指令類別的斷路器狀態，未啟用時為 CircuitClosed
+func (c *Cacher) CircuitState(class CommandClass) CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.breakers[class].currentState()
+}
+
+// circuitBreaker 單一斷路器
+type circuitBreaker struct {
+	opts BreakerOptions
+
+	mu          sync.Mutex
+	state       CircuitState
+	generation  uint64
+	openedAt    time.Time
+	windowStart time.Time
+	total       int
+	failures    int
+	slow        int
+	probes      int
+	successes   int
+}
+
+func newCircuitBreaker(opts BreakerOptions) *circuitBreaker {
+	return &circuitBreaker{opts: opts, windowStart: time.Now()}
+}
+
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.opts.CoolDown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow 判斷是否放行，返回放行時的 generation，結果需帶回 done
+func (b *circuitBreaker) allow(class CommandClass) (uint64, bool) {
+	b.mu.Lock()
+	from := b.state
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.opts.CoolDown {
+		b.setState(CircuitHalfOpen)
+	}
+	ok := true
+	switch b.state {
+	case CircuitOpen:
+		ok = false
+	case CircuitHalfOpen:
+		if b.probes >= b.opts.HalfOpenRequests {
+			ok = false
+		} else {
+			b.probes++
+		}
+	}
+	generation, to := b.generation, b.state
+	b.mu.Unlock()
+
+	b.notify(class, from, to)
+	return generation, ok
+}
+
+// done 記錄指令結果，狀態已改變時忽略之前放行的指令
+func (b *circuitBreaker) done(class CommandClass, generation uint64, err error, d time.Duration) {
+	failed := err != nil
+	slow := b.opts.SlowThreshold > 0 && d >= b.opts.SlowThreshold
+
+	b.mu.Lock()
+	from := b.state
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	switch b.state {
+	case CircuitHalfOpen:
+		if failed || slow {
+			b.setState(CircuitOpen)
+		} else if b.successes++; b.successes >= b.opts.HalfOpenRequests {
+			b.setState(CircuitClosed)
+		}
+	case CircuitClosed:
+		if time.Since(b.windowStart) >= b.opts.Window {
+			b.resetWindow()
+		}
+		b.total++
+		if failed {
+			b.failures++
+		}
+		if slow {
+			b.slow++
+		}
+		if b.total >= b.opts.MinRequests &&
+			(float64(b.failures)/float64(b.total) >= b.opts.ErrorRate ||
+				(b.opts.SlowThreshold > 0 && float64(b.slow)/float64(b.total) >= b.opts.SlowRate)) {
+			b.setState(CircuitOpen)
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(class, from, to)
+}
+
+// setState 需持有 mu
+func (b *circuitBreaker) setState(state CircuitState) {
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+	b.resetWindow()
+	if state == CircuitOpen {
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) resetWindow() {
+	b.windowStart = time.Now()
+	b.total = 0
+	b.failures = 0
+	b.slow = 0
+}
+
+func (b *circuitBreaker) notify(class CommandClass, from, to CircuitState) {
+	if from != to && b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(class, from, to)
+	}
+}
+
+// breakerError 斷路器計入的錯誤，只算連線、逾時及連接池的錯誤；
+// 伺服器回覆的錯誤(WRONGTYPE、NOSCRIPT、ERR 等)表示 redis 正常運作，redis nil 及呼叫端取消也不算
+func breakerError(err error) error {
+	err = commandError(err)
+	if err == context.Canceled || err == ErrCircuitOpen {
+		return nil
+	}
+	if _, ok := err.(redis.Error); ok {
+		return nil
+	}
+	return err
+}
+
+type breakerKey struct{}
+
+// skipBreakerKey 帶有此值的 context 不經過斷路器
+type skipBreakerKey struct{}
+
+// withoutBreaker 返回略過斷路器的 context，給健康檢查等需要知道 Redis 實際狀態的指令使用
+func withoutBreaker(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipBreakerKey{}, true)
+}
+
+type breakerCall struct {
+	class      CommandClass
+	generation uint64
+	start      time.Time
+	blocking   bool // 阻塞指令的耗時是等待資料的時間，不判斷是否為慢指令
+}
+
+// breakerHook 以 go-redis 的 hook 攔截指令
+type breakerHook struct {
+	breakers [2]*circuitBreaker
+}
+
+func (h *breakerHook) before(ctx context.Context, class CommandClass, blocking bool) (context.Context, error) {
+	if skip, _ := ctx.Value(skipBreakerKey{}).(bool); skip {
+		return ctx, nil
+	}
+	generation, ok := h.breakers[class].allow(class)
+	if !ok {
+		return ctx, ErrCircuitOpen
+	}
+	return context.WithValue(ctx, breakerKey{}, breakerCall{class: class, generation: generation, start: time.Now(), blocking: blocking}), nil
+}
+
+func (h *breakerHook) after(ctx context.Context, err error) {
+	call, ok := ctx.Value(breakerKey{}).(breakerCall)
+	if !ok {
+		return
+	}
+	var d time.Duration
+	if !call.blocking {
+		d = time.Since(call.start)
+	}
+	h.breakers[call.class].done(call.class, call.generation, breakerError(err), d)
+}
+
+func (h *breakerHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	_, blocking := blockDuration(cmd.Args())
+	return h.before(ctx, commandClass(cmd.Name()), blocking)
+}
+
+func (h *breakerHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.after(ctx, cmd.Err())
+	return nil
+}
+
+func (h *breakerHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	class := ClassRead
+	var blocking bool
+	for _, cmd := range cmds {
+		if commandClass(cmd.Name()) == ClassWrite {
+			class = ClassWrite
+		}
+		if _, ok := blockDuration(cmd.Args()); ok {
+			blocking = true
+		}
+	}
+	return h.before(ctx, class, blocking)
+}
+
+func (h *breakerHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if err = breakerError(cmd.Err()); err != nil {
+			break
+		}
+	}
+	h.after(ctx, err)
+	return nil
+}
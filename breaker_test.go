@@ -0,0 +1,168 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stateRecorder struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+func (r *stateRecorder) record(class CommandClass, from, to CircuitState) {
+	r.mu.Lock()
+	r.changes = append(r.changes, fmt.Sprintf("%s:%s->%s", class, from, to))
+	r.mu.Unlock()
+}
+
+func (r *stateRecorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fmt.Sprint(r.changes)
+}
+
+func TestCacher_EnableCircuitBreaker(t *testing.T) {
+	c, s := newTestCacher(t)
+	rec := &stateRecorder{}
+	c.EnableCircuitBreaker(BreakerOptions{
+		MinRequests:   2,
+		CoolDown:      50 * time.Millisecond,
+		OnStateChange: rec.record,
+	})
+
+	if err := c.Set("breaker-T1", "a", 0).Err; err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	s.Close()
+	c.Get("breaker-T1")
+	c.Get("breaker-T1")
+	if got := c.CircuitState(ClassRead); got != CircuitOpen {
+		t.Fatalf("CircuitState() = %v, want open", got)
+	}
+	start := time.Now()
+	if err := c.Get("breaker-T1").Err; err != ErrCircuitOpen {
+		t.Errorf("Get() error = %v, want ErrCircuitOpen", err)
+	}
+	if d := time.Since(start); d > 10*time.Millisecond {
+		t.Errorf("open circuit took %v", d)
+	}
+
+	if err := s.Restart(); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := c.CircuitState(ClassWrite); got != CircuitHalfOpen {
+		t.Errorf("CircuitState() = %v, want half-open", got)
+	}
+	if err := c.Set("breaker-T1", "b", 0).Err; err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+	if got := c.CircuitState(ClassRead); got != CircuitClosed {
+		t.Errorf("CircuitState() = %v, want closed", got)
+	}
+
+	want := "[read:closed->open write:open->half-open write:half-open->closed]"
+	if got := rec.String(); got != want {
+		t.Errorf("state changes = %s, want %s", got, want)
+	}
+}
+
+func TestCircuitBreaker_Blocking(t *testing.T) {
+	c, _ := newTestCacher(t)
+	c.EnableCircuitBreaker(BreakerOptions{
+		MinRequests:   1,
+		SlowThreshold: 10 * time.Millisecond,
+	})
+
+	// BLPOP 等到逾時不算慢指令
+	if err := c.BLPop("breaker-T4", 1).Err; err != ErrNil {
+		t.Fatalf("BLPop() error = %v, want ErrNil", err)
+	}
+	for _, class := range []CommandClass{ClassRead, ClassWrite} {
+		if got := c.CircuitState(class); got != CircuitClosed {
+			t.Errorf("CircuitState(%v) = %v, want closed", class, got)
+		}
+	}
+}
+
+type failHook struct {
+	recordHook
+	name string
+}
+
+func (h *failHook) BeforeProcess(ctx context.Context, cmd Command) (context.Context, error) {
+	if cmd.Name() == h.name {
+		return ctx, errors.New("injected")
+	}
+	return ctx, nil
+}
+
+func TestCircuitBreaker_PerClass(t *testing.T) {
+	c, _ := newTestCacher(t)
+	c.EnableCircuitBreaker(BreakerOptions{MinRequests: 2, PerClass: true})
+	c.AddHook(&failHook{name: "set"})
+
+	c.Set("breaker-T2", "a", 0)
+	c.Set("breaker-T2", "a", 0)
+	if err := c.Set("breaker-T2", "a", 0).Err; err != ErrCircuitOpen {
+		t.Errorf("Set() error = %v, want ErrCircuitOpen", err)
+	}
+	if err := c.Get("breaker-T2").Err; err != ErrNil {
+		t.Errorf("Get() error = %v, want ErrNil", err)
+	}
+	if got := c.CircuitState(ClassRead); got != CircuitClosed {
+		t.Errorf("CircuitState(ClassRead) = %v, want closed", got)
+	}
+}
+
+func TestCircuitBreaker_IgnoreReplyErrors(t *testing.T) {
+	c, _ := newTestCacher(t)
+	c.EnableCircuitBreaker(BreakerOptions{MinRequests: 2})
+
+	c.HSet("breaker-T3", "f", 1)
+	for i := 0; i < 5; i++ {
+		if err := c.Get("breaker-T3").Err; err == nil || err == ErrCircuitOpen {
+			t.Fatalf("Get() error = %v, want WRONGTYPE", err)
+		}
+	}
+	if got := c.CircuitState(ClassRead); got != CircuitClosed {
+		t.Errorf("CircuitState() = %v, want closed", got)
+	}
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	b := newCircuitBreaker(BreakerOptions{Window: time.Second, MinRequests: 1, ErrorRate: 0.5, CoolDown: time.Millisecond, HalfOpenRequests: 1})
+
+	gen, _ := b.allow(ClassWrite)
+	b.done(ClassWrite, gen, errors.New("boom"), 0)
+	time.Sleep(2 * time.Millisecond)
+
+	gen, ok := b.allow(ClassWrite)
+	if !ok {
+		t.Fatalf("allow() = false in half-open")
+	}
+	if _, ok := b.allow(ClassWrite); ok {
+		t.Errorf("allow() = true, want only one probe")
+	}
+	b.done(ClassWrite, gen, nil, 10*time.Millisecond)
+	if got := b.currentState(); got != CircuitClosed {
+		t.Errorf("state = %v, want closed", got)
+	}
+}
+
+func TestCircuitBreaker_Slow(t *testing.T) {
+	b := newCircuitBreaker(BreakerOptions{Window: time.Second, MinRequests: 2, ErrorRate: 0.5, SlowThreshold: time.Millisecond, SlowRate: 0.5, CoolDown: time.Hour, HalfOpenRequests: 1})
+
+	for i := 0; i < 2; i++ {
+		gen, _ := b.allow(ClassRead)
+		b.done(ClassRead, gen, nil, 5*time.Millisecond)
+	}
+	if got := b.currentState(); got != CircuitOpen {
+		t.Errorf("state = %v, want open", got)
+	}
+}
@@ -0,0 +1,473 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Config 以 time.Duration 設定的配置，取代 Options 的秒數欄位，可由 ParseURL 或 ConfigFromEnv 產生
+type Config struct {
+	Addr       string // redis服務的地址 host:port
+	Username   string // ACL 使用者名稱，redis 6 以上，需同時設定 Password
+	Password   string // redis鑒權密碼
+	DB         int    // 數據庫
+	ClientName string // 建立連接後以 CLIENT SETNAME 設定的連接名稱，方便在 CLIENT LIST 辨識
+
+	MaxRetries   int                 // 唯讀及冪等指令放棄前會重試幾次，預設3，-1 表示不重試
+	PoolSize     int                 // 池子大小，預設每個 CPU 10 個
+	MinIdleConns int                 // 最小空閒連接數
+	DialTimeout  time.Duration       // 建立連接的逾時，預設5秒
+	ReadTimeout  time.Duration       // socket 讀取逾時，預設3秒
+	WriteTimeout time.Duration       // socket 寫入逾時，預設為 ReadTimeout
+	PoolTimeout  time.Duration       // 等待連接池的逾時，預設為 ReadTimeout + 1秒
+	IdleTimeout  time.Duration       // 空閑連接的超時時間，預設5分鐘
+	MaxConnAge   time.Duration       // 連接的最大存活時間，預設不會關閉
+	TLS          *TLSOptions         // 設定時以 TLS 連線
+	Replicas     ReplicaOptions      // 從庫地址，設定時唯讀指令改由從庫執行
+	Blocking     BlockingPoolOptions // 阻塞指令專用的連接池，PoolSize 大於0時啟用
+
+	Prefix          string                   // 鍵名前綴
+	PrefixChannels  bool                     // 發布訂閱的頻道名稱也加上 Prefix
+	CommandTimeout  time.Duration            // 每個指令的預設逾時，見 TimeoutOptions.Default
+	CommandTimeouts map[string]time.Duration // 依指令名稱設定的逾時，見 TimeoutOptions.Commands
+	Retry           *RetryPolicy             // 依指令決定的重試策略，設定時取代 MaxRetries
+	Debug           bool                     // 以 Debug 等級記錄每個指令
+	Logger          Logger                   // 日誌介面
+	LogStatement    StatementMode            // Debug 時指令參數的記錄方式
+	LogMaxLen       int                      // Debug 時指令紀錄的最大長度
+}
+
+// TLSOptions TLS 連線設定
+type TLSOptions struct {
+	CAFile             string      // 驗證伺服器憑證的 CA(PEM)，未設定時使用系統的 CA
+	CertFile           string      // 雙向 TLS 的用戶端憑證(PEM)，需同時設定 KeyFile
+	KeyFile            string      // 用戶端憑證的私鑰(PEM)
+	ServerName         string      // 驗證憑證用的主機名稱，預設為 Addr 的主機
+	InsecureSkipVerify bool        // 不驗證伺服器憑證，只用於測試環境
+	Config             *tls.Config // 直接指定 tls.Config，設定時忽略其他欄位
+}
+
+// NewWithConfig 根據 Config 創建redis工具實例，設定不合法時返回錯誤
+func NewWithConfig(cfg Config) (*Cacher, error) {
+	r := &Cacher{}
+	if err := r.StartAndGC(cfg); err != nil {
+		return nil, fmt.Errorf("start and GC error: %s", err)
+	}
+	if err := r.Do("PING").Err; err != nil {
+		return nil, fmt.Errorf("PONG error: %s", err)
+	}
+	return r, nil
+}
+
+// Validate 檢查設定，返回所有不合法的欄位
+func (cfg Config) Validate() error {
+	var problems []string
+	if cfg.Addr == "" {
+		problems = append(problems, "miss Addr")
+	} else if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		problems = append(problems, fmt.Sprintf("Addr %q: %s", cfg.Addr, err))
+	}
+	if cfg.Username != "" && cfg.Password == "" {
+		problems = append(problems, "Username requires Password")
+	}
+	if cfg.DB < 0 {
+		problems = append(problems, "DB must not be negative")
+	}
+	if cfg.MaxRetries < -1 {
+		problems = append(problems, "MaxRetries must be -1 or more")
+	}
+	if cfg.PoolSize < 0 || cfg.MinIdleConns < 0 {
+		problems = append(problems, "PoolSize and MinIdleConns must not be negative")
+	}
+	if cfg.PoolSize > 0 && cfg.MinIdleConns > cfg.PoolSize {
+		problems = append(problems, "MinIdleConns exceeds PoolSize")
+	}
+	if cfg.Blocking.PoolSize < 0 || cfg.Blocking.MinIdleConns > cfg.Blocking.PoolSize {
+		problems = append(problems, "Blocking.PoolSize must not be negative or less than Blocking.MinIdleConns")
+	}
+	durations := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"DialTimeout", cfg.DialTimeout}, {"ReadTimeout", cfg.ReadTimeout}, {"WriteTimeout", cfg.WriteTimeout},
+		{"PoolTimeout", cfg.PoolTimeout}, {"IdleTimeout", cfg.IdleTimeout}, {"MaxConnAge", cfg.MaxConnAge},
+		{"CommandTimeout", cfg.CommandTimeout}, {"Blocking.PoolTimeout", cfg.Blocking.PoolTimeout},
+		{"Blocking.ReadTimeout", cfg.Blocking.ReadTimeout}, {"Blocking.IdleTimeout", cfg.Blocking.IdleTimeout},
+	}
+	for _, d := range durations {
+		if d.d < 0 {
+			problems = append(problems, d.name+" must not be negative")
+		}
+	}
+	for _, addr := range cfg.Replicas.Addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			problems = append(problems, fmt.Sprintf("replica Addr %q: %s", addr, err))
+		}
+	}
+	if tlsOpts := cfg.TLS; tlsOpts != nil && tlsOpts.Config == nil && (tlsOpts.CertFile == "") != (tlsOpts.KeyFile == "") {
+		problems = append(problems, "TLS CertFile and KeyFile must be set together")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// redisOptions 轉換為 go-redis 的設定
+func (cfg Config) redisOptions() (*redis.Options, error) {
+	redisOption := &redis.Options{
+		Addr:         cfg.Addr,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		MaxRetries:   -1, // 重試由 doWithRetry 依指令決定，go-redis 不再重試
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		PoolTimeout:  cfg.PoolTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		MaxConnAge:   cfg.MaxConnAge,
+	}
+	if cfg.TLS != nil {
+		tlsConfig, err := cfg.TLS.tlsConfig(cfg.Addr)
+		if err != nil {
+			return nil, err
+		}
+		redisOption.TLSConfig = tlsConfig
+	}
+	if cfg.ClientName != "" {
+		name := cfg.ClientName
+		redisOption.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+			return cn.ClientSetName(ctx, name).Err()
+		}
+	}
+	return redisOption, nil
+}
+
+// tlsConfig 產生 tls.Config，ServerName 預設為 addr 的主機
+func (t *TLSOptions) tlsConfig(addr string) (*tls.Config, error) {
+	if t.Config != nil {
+		return t.Config, nil
+	}
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read TLS CA: %s", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("read TLS CA: no certificate in %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// configParams URL 參數及環境變數可設定的欄位，環境變數名稱為前綴加上大寫的參數名稱，例如 REDIS_DIAL_TIMEOUT
+var configParams = map[string]func(cfg *Config, v string) error{
+	"addr":            func(cfg *Config, v string) error { cfg.Addr = v; return nil },
+	"username":        func(cfg *Config, v string) error { cfg.Username = v; return nil },
+	"password":        func(cfg *Config, v string) error { cfg.Password = v; return nil },
+	"db":              intParam(func(cfg *Config) *int { return &cfg.DB }),
+	"client_name":     func(cfg *Config, v string) error { cfg.ClientName = v; return nil },
+	"key_prefix":      func(cfg *Config, v string) error { cfg.Prefix = v; return nil },
+	"prefix_channels": boolParam(func(cfg *Config) *bool { return &cfg.PrefixChannels }),
+	"max_retries":     intParam(func(cfg *Config) *int { return &cfg.MaxRetries }),
+	"pool_size":       intParam(func(cfg *Config) *int { return &cfg.PoolSize }),
+	"min_idle_conns":  intParam(func(cfg *Config) *int { return &cfg.MinIdleConns }),
+	"dial_timeout":    durationParam(func(cfg *Config) *time.Duration { return &cfg.DialTimeout }),
+	"read_timeout":    durationParam(func(cfg *Config) *time.Duration { return &cfg.ReadTimeout }),
+	"write_timeout":   durationParam(func(cfg *Config) *time.Duration { return &cfg.WriteTimeout }),
+	"pool_timeout":    durationParam(func(cfg *Config) *time.Duration { return &cfg.PoolTimeout }),
+	"idle_timeout":    durationParam(func(cfg *Config) *time.Duration { return &cfg.IdleTimeout }),
+	"max_conn_age":    durationParam(func(cfg *Config) *time.Duration { return &cfg.MaxConnAge }),
+	"command_timeout": durationParam(func(cfg *Config) *time.Duration { return &cfg.CommandTimeout }),
+	"replicas": func(cfg *Config, v string) error {
+		cfg.Replicas.Addrs = strings.Split(v, ",")
+		return nil
+	},
+	"replica_routing":       setReplicaRouting,
+	"blocking_pool_size":    intParam(func(cfg *Config) *int { return &cfg.Blocking.PoolSize }),
+	"blocking_pool_timeout": durationParam(func(cfg *Config) *time.Duration { return &cfg.Blocking.PoolTimeout }),
+	"blocking_read_timeout": durationParam(func(cfg *Config) *time.Duration { return &cfg.Blocking.ReadTimeout }),
+	"debug":                 boolParam(func(cfg *Config) *bool { return &cfg.Debug }),
+	"tls":                   setTLS,
+	"tls_ca":                tlsParam(func(t *TLSOptions, v string) error { t.CAFile = v; return nil }),
+	"tls_cert":              tlsParam(func(t *TLSOptions, v string) error { t.CertFile = v; return nil }),
+	"tls_key":               tlsParam(func(t *TLSOptions, v string) error { t.KeyFile = v; return nil }),
+	"tls_server_name":       tlsParam(func(t *TLSOptions, v string) error { t.ServerName = v; return nil }),
+	"tls_insecure_skip_verify": tlsParam(func(t *TLSOptions, v string) (err error) {
+		t.InsecureSkipVerify, err = strconv.ParseBool(v)
+		return err
+	}),
+}
+
+func intParam(field func(cfg *Config) *int) func(cfg *Config, v string) error {
+	return func(cfg *Config, v string) (err error) {
+		*field(cfg), err = strconv.Atoi(v)
+		return err
+	}
+}
+
+func boolParam(field func(cfg *Config) *bool) func(cfg *Config, v string) error {
+	return func(cfg *Config, v string) (err error) {
+		*field(cfg), err = strconv.ParseBool(v)
+		return err
+	}
+}
+
+// durationParam 接受 time.ParseDuration 的格式，純數字視為秒數，與 Options 相同
+func durationParam(field func(cfg *Config) *time.Duration) func(cfg *Config, v string) error {
+	return func(cfg *Config, v string) error {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			*field(cfg) = time.Duration(seconds) * time.Second
+			return nil
+		}
+		d, err := time.ParseDuration(v)
+		*field(cfg) = d
+		return err
+	}
+}
+
+// tlsParam 設定 TLS 欄位並啟用 TLS
+func tlsParam(set func(t *TLSOptions, v string) error) func(cfg *Config, v string) error {
+	return func(cfg *Config, v string) error {
+		if cfg.TLS == nil {
+			cfg.TLS = &TLSOptions{}
+		}
+		return set(cfg.TLS, v)
+	}
+}
+
+// setReplicaRouting round_robin 或 latency
+func setReplicaRouting(cfg *Config, v string) error {
+	switch v {
+	case "round_robin":
+		cfg.Replicas.Routing = RouteRoundRobin
+	case "latency":
+		cfg.Replicas.Routing = RouteLowestLatency
+	default:
+		return errors.New("want round_robin or latency")
+	}
+	return nil
+}
+
+// setTLS tls=true 以 TLS 連線，false 時關閉
+func setTLS(cfg *Config, v string) error {
+	enable, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	if !enable {
+		cfg.TLS = nil
+	} else if cfg.TLS == nil {
+		cfg.TLS = &TLSOptions{}
+	}
+	return nil
+}
+
+// setParam 依參數名稱設定欄位，不認得的參數返回錯誤
+func (cfg *Config) setParam(name, value string) error {
+	set, ok := configParams[name]
+	if !ok {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	if err := set(cfg, value); err != nil {
+		return fmt.Errorf("invalid %s %q: %s", name, value, err)
+	}
+	return nil
+}
+
+// ParseURL 解析 redis://[[username]:password@]host[:port][/db][?參數=值] 格式的設定，
+// rediss:// 以 TLS 連線，參數名稱見 README，例如 ?client_name=api&dial_timeout=500ms&pool_size=20
+func ParseURL(rawURL string) (Config, error) {
+	var cfg Config
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return cfg, err
+	}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		cfg.TLS = &TLSOptions{}
+	default:
+		return cfg, fmt.Errorf("invalid URL scheme %q, want redis or rediss", u.Scheme)
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "6379"
+	}
+	cfg.Addr = net.JoinHostPort(host, port)
+	if u.User != nil {
+		cfg.Username = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			cfg.Password = password
+		} else {
+			// redis://password@host 的舊格式，與 go-redis 相同
+			cfg.Username, cfg.Password = "", cfg.Username
+		}
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		if cfg.DB, err = strconv.Atoi(path); err != nil {
+			return cfg, fmt.Errorf("invalid database number %q", path)
+		}
+	}
+
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := cfg.setParam(name, query.Get(name)); err != nil {
+			return cfg, err
+		}
+	}
+	if u.Scheme == "redis" && cfg.TLS != nil {
+		return cfg, errors.New("TLS parameters require rediss:// scheme")
+	}
+	return cfg, nil
+}
+
+// ConfigFromEnv 從環境變數讀取設定，prefix 預設 "REDIS"。
+// 先解析 REDIS_URL，再以 REDIS_ADDR、REDIS_PASSWORD、REDIS_DIAL_TIMEOUT 等個別變數覆蓋
+func ConfigFromEnv(prefix string) (Config, error) {
+	if prefix == "" {
+		prefix = "REDIS"
+	}
+	prefix = strings.TrimSuffix(prefix, "_") + "_"
+
+	var cfg Config
+	if rawURL, ok := os.LookupEnv(prefix + "URL"); ok && rawURL != "" {
+		var err error
+		if cfg, err = ParseURL(rawURL); err != nil {
+			return cfg, fmt.Errorf("%sURL: %s", prefix, err)
+		}
+	}
+
+	names := make([]string, 0, len(configParams))
+	for name := range configParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := prefix + strings.ToUpper(name)
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			if err := cfg.setParam(name, value); err != nil {
+				return cfg, fmt.Errorf("%s: %s", key, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// Config 將 Options 轉換為 Config，秒數欄位轉為 time.Duration，Timeouts 的設定優先
+func (o Options) Config() Config {
+	seconds := func(n int) time.Duration { return time.Duration(n) * time.Second }
+	cfg := Config{
+		Addr:            o.Addr,
+		Password:        o.Password,
+		DB:              o.Db,
+		MaxRetries:      o.MaxRetries,
+		PoolSize:        o.PoolSize,
+		MinIdleConns:    o.MinIdle,
+		DialTimeout:     seconds(o.DialTimeout),
+		ReadTimeout:     seconds(o.ReadTimeout),
+		WriteTimeout:    seconds(o.WriteTimeout),
+		PoolTimeout:     seconds(o.PoolTimeout),
+		IdleTimeout:     seconds(o.IdleTimeout),
+		MaxConnAge:      seconds(o.MaxConnAge),
+		Prefix:          o.Prefix,
+		PrefixChannels:  o.PrefixChannels,
+		CommandTimeout:  o.Timeouts.Default,
+		CommandTimeouts: o.Timeouts.Commands,
+		Retry:           o.Retry,
+		Debug:           o.Debug,
+		Logger:          o.Logger,
+		LogStatement:    o.LogStatement,
+		LogMaxLen:       o.LogMaxLen,
+		Replicas:        o.Replicas,
+		Blocking:        o.Blocking,
+	}
+	if o.Timeouts.Dial > 0 {
+		cfg.DialTimeout = o.Timeouts.Dial
+	}
+	if o.Timeouts.Read > 0 {
+		cfg.ReadTimeout = o.Timeouts.Read
+	}
+	if o.Timeouts.Write > 0 {
+		cfg.WriteTimeout = o.Timeouts.Write
+	}
+	if o.Timeouts.Pool > 0 {
+		cfg.PoolTimeout = o.Timeouts.Pool
+	}
+	if cfg.Logger == nil && o.Log != nil {
+		level := LevelInfo
+		if o.Debug {
+			level = LevelDebug
+		}
+		cfg.Logger = NewStdLogger(o.Log, level)
+	}
+	return cfg
+}
+
+// ignoredFields 設定了但已無作用的欄位
+func (o Options) ignoredFields() []string {
+	var fields []string
+	if o.MaxActive != 0 {
+		fields = append(fields, "MaxActive")
+	}
+	if o.MaxIdle != 0 {
+		fields = append(fields, "MaxIdle")
+	}
+	if o.Wait {
+		fields = append(fields, "Wait")
+	}
+	return fields
+}
+
+// Validate 檢查設定，Strict 時設定了已無作用的欄位(MaxActive、MaxIdle、Wait)也返回錯誤
+func (o Options) Validate() error {
+	if o.Addr == "" {
+		return errors.New("miss Addr")
+	}
+	if ignored := o.ignoredFields(); o.Strict && len(ignored) > 0 {
+		return fmt.Errorf("invalid options: %s no longer have any effect", strings.Join(ignored, ", "))
+	}
+	return o.Config().Validate()
+}
@@ -0,0 +1,446 @@
+package redis
+
+import "context"
+
+/**
+每個指令都有對應的 Ctx 版本，以第一個參數的 ctx 控制逾時及取消，
+WithContext 設定的追蹤欄位會保留，例如 redisClient.WithContext(ctx, "traceID").GetCtx(reqCtx, "key")
+*/
+
+// withCtx 產生使用 ctx 的 Cacher，保留 WithContext 設定的追蹤欄位
+func (c *Cacher) withCtx(ctx context.Context) *Cacher {
+	if ctx == nil {
+		panic("nil context")
+	}
+	clone := c.clone()
+	clone.ctx.Context = ctx
+	return clone
+}
+
+// 鍵值、雜湊、串列、集合、有序集合及腳本
+
+// DoCtx 同 Do，使用 ctx
+func (c *Cacher) DoCtx(ctx context.Context, commandName string, args ...interface{}) *Cmd {
+	return c.withCtx(ctx).Do(commandName, args...)
+}
+
+// GetCtx 同 Get，使用 ctx
+func (c *Cacher) GetCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).Get(key)
+}
+
+// SetCtx 同 Set，使用 ctx
+func (c *Cacher) SetCtx(ctx context.Context, key string, val interface{}, expire int64) *Cmd {
+	return c.withCtx(ctx).Set(key, val, expire)
+}
+
+// ExpireCtx 同 Expire，使用 ctx
+func (c *Cacher) ExpireCtx(ctx context.Context, key string, expire int64) *Cmd {
+	return c.withCtx(ctx).Expire(key, expire)
+}
+
+// ExpireAtCtx 同 ExpireAt，使用 ctx
+func (c *Cacher) ExpireAtCtx(ctx context.Context, key string, expireAt int64) *Cmd {
+	return c.withCtx(ctx).ExpireAt(key, expireAt)
+}
+
+// TTLCtx 同 TTL，使用 ctx
+func (c *Cacher) TTLCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).TTL(key)
+}
+
+// KeysCtx 同 Keys，使用 ctx
+func (c *Cacher) KeysCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).Keys(key)
+}
+
+// DelCtx 同 Del，使用 ctx
+func (c *Cacher) DelCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).Del(key)
+}
+
+// IncrByCtx 同 IncrBy，使用 ctx
+func (c *Cacher) IncrByCtx(ctx context.Context, key string, amount int64) *Cmd {
+	return c.withCtx(ctx).IncrBy(key, amount)
+}
+
+// DecrByCtx 同 DecrBy，使用 ctx
+func (c *Cacher) DecrByCtx(ctx context.Context, key string, amount int64) *Cmd {
+	return c.withCtx(ctx).DecrBy(key, amount)
+}
+
+// SetNXCtx 同 SetNX，使用 ctx
+func (c *Cacher) SetNXCtx(ctx context.Context, key string, val interface{}, expire int64) *Cmd {
+	return c.withCtx(ctx).SetNX(key, val, expire)
+}
+
+// HMSetCtx 同 HMSet，使用 ctx
+func (c *Cacher) HMSetCtx(ctx context.Context, key string, expire int, val ...interface{}) *Cmd {
+	return c.withCtx(ctx).HMSet(key, expire, val...)
+}
+
+// HSetCtx 同 HSet，使用 ctx
+func (c *Cacher) HSetCtx(ctx context.Context, key string, val ...interface{}) *Cmd {
+	return c.withCtx(ctx).HSet(key, val...)
+}
+
+// HGetCtx 同 HGet，使用 ctx
+func (c *Cacher) HGetCtx(ctx context.Context, key, field string) *Cmd {
+	return c.withCtx(ctx).HGet(key, field)
+}
+
+// HGetAllCtx 同 HGetAll，使用 ctx
+func (c *Cacher) HGetAllCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).HGetAll(key)
+}
+
+// HDelCtx 同 HDel，使用 ctx
+func (c *Cacher) HDelCtx(ctx context.Context, key string, fileds ...interface{}) *Cmd {
+	return c.withCtx(ctx).HDel(key, fileds...)
+}
+
+// HExistsCtx 同 HExists，使用 ctx
+func (c *Cacher) HExistsCtx(ctx context.Context, key, field string) *Cmd {
+	return c.withCtx(ctx).HExists(key, field)
+}
+
+// HLenCtx 同 HLen，使用 ctx
+func (c *Cacher) HLenCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).HLen(key)
+}
+
+// HKeysCtx 同 HKeys，使用 ctx
+func (c *Cacher) HKeysCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).HKeys(key)
+}
+
+// HIncrbyCtx 同 HIncrby，使用 ctx
+func (c *Cacher) HIncrbyCtx(ctx context.Context, key, field string, number int) *Cmd {
+	return c.withCtx(ctx).HIncrby(key, field, number)
+}
+
+// HSetNXCtx 同 HSetNX，使用 ctx
+func (c *Cacher) HSetNXCtx(ctx context.Context, key, field string, value interface{}) *Cmd {
+	return c.withCtx(ctx).HSetNX(key, field, value)
+}
+
+// BLPopCtx 同 BLPop，使用 ctx
+func (c *Cacher) BLPopCtx(ctx context.Context, key string, timeout int) *Cmd {
+	return c.withCtx(ctx).BLPop(key, timeout)
+}
+
+// BRPopCtx 同 BRPop，使用 ctx
+func (c *Cacher) BRPopCtx(ctx context.Context, key string, timeout int) *Cmd {
+	return c.withCtx(ctx).BRPop(key, timeout)
+}
+
+// BRPopLPushCtx 同 BRPopLPush，使用 ctx
+func (c *Cacher) BRPopLPushCtx(ctx context.Context, source, destination string, timeout int) *Cmd {
+	return c.withCtx(ctx).BRPopLPush(source, destination, timeout)
+}
+
+// BLMoveCtx 同 BLMove，使用 ctx
+func (c *Cacher) BLMoveCtx(ctx context.Context, source, destination, srcPos, dstPos string, timeout int) *Cmd {
+	return c.withCtx(ctx).BLMove(source, destination, srcPos, dstPos, timeout)
+}
+
+// LRemCtx 同 LRem，使用 ctx
+func (c *Cacher) LRemCtx(ctx context.Context, key string, count int64, value interface{}) *Cmd {
+	return c.withCtx(ctx).LRem(key, count, value)
+}
+
+// LPopCtx 同 LPop，使用 ctx
+func (c *Cacher) LPopCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).LPop(key)
+}
+
+// RPopCtx 同 RPop，使用 ctx
+func (c *Cacher) RPopCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).RPop(key)
+}
+
+// LPushCtx 同 LPush，使用 ctx
+func (c *Cacher) LPushCtx(ctx context.Context, key string, member ...interface{}) *Cmd {
+	return c.withCtx(ctx).LPush(key, member...)
+}
+
+// LTrimCtx 同 LTrim，使用 ctx
+func (c *Cacher) LTrimCtx(ctx context.Context, key string, start, stop int32) *Cmd {
+	return c.withCtx(ctx).LTrim(key, start, stop)
+}
+
+// RPushCtx 同 RPush，使用 ctx
+func (c *Cacher) RPushCtx(ctx context.Context, key string, member ...interface{}) *Cmd {
+	return c.withCtx(ctx).RPush(key, member...)
+}
+
+// LLenCtx 同 LLen，使用 ctx
+func (c *Cacher) LLenCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).LLen(key)
+}
+
+// LRangeCtx 同 LRange，使用 ctx
+func (c *Cacher) LRangeCtx(ctx context.Context, key string, start, end int) *Cmd {
+	return c.withCtx(ctx).LRange(key, start, end)
+}
+
+// ZAddCtx 同 ZAdd，使用 ctx
+func (c *Cacher) ZAddCtx(ctx context.Context, key string, score int64, member string) *Cmd {
+	return c.withCtx(ctx).ZAdd(key, score, member)
+}
+
+// ZAddFloatCtx 同 ZAddFloat，使用 ctx
+func (c *Cacher) ZAddFloatCtx(ctx context.Context, key string, score float64, member string) *Cmd {
+	return c.withCtx(ctx).ZAddFloat(key, score, member)
+}
+
+// ZRemCtx 同 ZRem，使用 ctx
+func (c *Cacher) ZRemCtx(ctx context.Context, key string, member string) *Cmd {
+	return c.withCtx(ctx).ZRem(key, member)
+}
+
+// ZScoreCtx 同 ZScore，使用 ctx
+func (c *Cacher) ZScoreCtx(ctx context.Context, key string, member string) *Cmd {
+	return c.withCtx(ctx).ZScore(key, member)
+}
+
+// ZRankCtx 同 ZRank，使用 ctx
+func (c *Cacher) ZRankCtx(ctx context.Context, key, member string) *Cmd {
+	return c.withCtx(ctx).ZRank(key, member)
+}
+
+// ZRevrankCtx 同 ZRevrank，使用 ctx
+func (c *Cacher) ZRevrankCtx(ctx context.Context, key, member string) *Cmd {
+	return c.withCtx(ctx).ZRevrank(key, member)
+}
+
+// ZRangeCtx 同 ZRange，使用 ctx
+func (c *Cacher) ZRangeCtx(ctx context.Context, key string, from, to int64) *Cmd {
+	return c.withCtx(ctx).ZRange(key, from, to)
+}
+
+// ZRangeWithScoreCtx 同 ZRangeWithScore，使用 ctx
+func (c *Cacher) ZRangeWithScoreCtx(ctx context.Context, key string, from, to int64) *Cmd {
+	return c.withCtx(ctx).ZRangeWithScore(key, from, to)
+}
+
+// ZRevrangeCtx 同 ZRevrange，使用 ctx
+func (c *Cacher) ZRevrangeCtx(ctx context.Context, key string, from, to int64) *Cmd {
+	return c.withCtx(ctx).ZRevrange(key, from, to)
+}
+
+// ZRangeByScoreCtx 同 ZRangeByScore，使用 ctx
+func (c *Cacher) ZRangeByScoreCtx(ctx context.Context, key string, from, to, offset int64, count int) *Cmd {
+	return c.withCtx(ctx).ZRangeByScore(key, from, to, offset, count)
+}
+
+// ZRevrangeByScoreCtx 同 ZRevrangeByScore，使用 ctx
+func (c *Cacher) ZRevrangeByScoreCtx(ctx context.Context, key string, from, to, offset int64, count int) *Cmd {
+	return c.withCtx(ctx).ZRevrangeByScore(key, from, to, offset, count)
+}
+
+// ZCardCtx 同 ZCard，使用 ctx
+func (c *Cacher) ZCardCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).ZCard(key)
+}
+
+// SAddCtx 同 SAdd，使用 ctx
+func (c *Cacher) SAddCtx(ctx context.Context, key, member string) *Cmd {
+	return c.withCtx(ctx).SAdd(key, member)
+}
+
+// SRemCtx 同 SRem，使用 ctx
+func (c *Cacher) SRemCtx(ctx context.Context, key, member string) *Cmd {
+	return c.withCtx(ctx).SRem(key, member)
+}
+
+// SCardCtx 同 SCard，使用 ctx
+func (c *Cacher) SCardCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).SCard(key)
+}
+
+// SPopCtx 同 SPop，使用 ctx
+func (c *Cacher) SPopCtx(ctx context.Context, key string, count int) *Cmd {
+	return c.withCtx(ctx).SPop(key, count)
+}
+
+// SisMembersCtx 同 SisMembers，使用 ctx
+func (c *Cacher) SisMembersCtx(ctx context.Context, key, member string) *Cmd {
+	return c.withCtx(ctx).SisMembers(key, member)
+}
+
+// SMembersCtx 同 SMembers，使用 ctx
+func (c *Cacher) SMembersCtx(ctx context.Context, key string) *Cmd {
+	return c.withCtx(ctx).SMembers(key)
+}
+
+// ScanCtx 同 Scan，使用 ctx
+func (c *Cacher) ScanCtx(ctx context.Context, cursor, count int, match string) *Cmd {
+	return c.withCtx(ctx).Scan(cursor, count, match)
+}
+
+// ScriptLoadCtx 同 ScriptLoad，使用 ctx
+func (c *Cacher) ScriptLoadCtx(ctx context.Context, script string) (string, error) {
+	return c.withCtx(ctx).ScriptLoad(script)
+}
+
+// EvalShaCtx 同 EvalSha，使用 ctx
+func (c *Cacher) EvalShaCtx(ctx context.Context, script string, keys []string, args ...interface{}) *Cmd {
+	return c.withCtx(ctx).EvalSha(script, keys, args...)
+}
+
+// Stream
+
+// XAddCtx 同 XAdd，使用 ctx
+func (c *Cacher) XAddCtx(ctx context.Context, a *XAddArgs) *Cmd {
+	return c.withCtx(ctx).XAdd(a)
+}
+
+// XLenCtx 同 XLen，使用 ctx
+func (c *Cacher) XLenCtx(ctx context.Context, stream string) *Cmd {
+	return c.withCtx(ctx).XLen(stream)
+}
+
+// XDelCtx 同 XDel，使用 ctx
+func (c *Cacher) XDelCtx(ctx context.Context, stream string, ids ...string) *Cmd {
+	return c.withCtx(ctx).XDel(stream, ids...)
+}
+
+// XTrimMaxLenCtx 同 XTrimMaxLen，使用 ctx
+func (c *Cacher) XTrimMaxLenCtx(ctx context.Context, stream string, maxLen int64, approx bool) *Cmd {
+	return c.withCtx(ctx).XTrimMaxLen(stream, maxLen, approx)
+}
+
+// XTrimMinIDCtx 同 XTrimMinID，使用 ctx
+func (c *Cacher) XTrimMinIDCtx(ctx context.Context, stream string, minID string, approx bool) *Cmd {
+	return c.withCtx(ctx).XTrimMinID(stream, minID, approx)
+}
+
+// XRangeCtx 同 XRange，使用 ctx
+func (c *Cacher) XRangeCtx(ctx context.Context, stream, start, stop string, count int64) ([]XMessage, error) {
+	return c.withCtx(ctx).XRange(stream, start, stop, count)
+}
+
+// XRevRangeCtx 同 XRevRange，使用 ctx
+func (c *Cacher) XRevRangeCtx(ctx context.Context, stream, end, start string, count int64) ([]XMessage, error) {
+	return c.withCtx(ctx).XRevRange(stream, end, start, count)
+}
+
+// XReadCtx 同 XRead，使用 ctx
+func (c *Cacher) XReadCtx(ctx context.Context, a *XReadArgs) ([]XStream, error) {
+	return c.withCtx(ctx).XRead(a)
+}
+
+// XReadGroupCtx 同 XReadGroup，使用 ctx
+func (c *Cacher) XReadGroupCtx(ctx context.Context, a *XReadGroupArgs) ([]XStream, error) {
+	return c.withCtx(ctx).XReadGroup(a)
+}
+
+// XGroupCreateCtx 同 XGroupCreate，使用 ctx
+func (c *Cacher) XGroupCreateCtx(ctx context.Context, stream, group, start string, mkStream bool) *Cmd {
+	return c.withCtx(ctx).XGroupCreate(stream, group, start, mkStream)
+}
+
+// XGroupDestroyCtx 同 XGroupDestroy，使用 ctx
+func (c *Cacher) XGroupDestroyCtx(ctx context.Context, stream, group string) *Cmd {
+	return c.withCtx(ctx).XGroupDestroy(stream, group)
+}
+
+// XGroupDelConsumerCtx 同 XGroupDelConsumer，使用 ctx
+func (c *Cacher) XGroupDelConsumerCtx(ctx context.Context, stream, group, consumer string) *Cmd {
+	return c.withCtx(ctx).XGroupDelConsumer(stream, group, consumer)
+}
+
+// XAckCtx 同 XAck，使用 ctx
+func (c *Cacher) XAckCtx(ctx context.Context, stream, group string, ids ...string) *Cmd {
+	return c.withCtx(ctx).XAck(stream, group, ids...)
+}
+
+// XPendingCtx 同 XPending，使用 ctx
+func (c *Cacher) XPendingCtx(ctx context.Context, stream, group string) (*XPending, error) {
+	return c.withCtx(ctx).XPending(stream, group)
+}
+
+// XPendingExtCtx 同 XPendingExt，使用 ctx
+func (c *Cacher) XPendingExtCtx(ctx context.Context, a *XPendingExtArgs) ([]XPendingEntry, error) {
+	return c.withCtx(ctx).XPendingExt(a)
+}
+
+// XClaimCtx 同 XClaim，使用 ctx
+func (c *Cacher) XClaimCtx(ctx context.Context, a *XClaimArgs) ([]XMessage, error) {
+	return c.withCtx(ctx).XClaim(a)
+}
+
+// XAutoClaimCtx 同 XAutoClaim，使用 ctx
+func (c *Cacher) XAutoClaimCtx(ctx context.Context, a *XAutoClaimArgs) ([]XMessage, string, error) {
+	return c.withCtx(ctx).XAutoClaim(a)
+}
+
+// XInfoStreamCtx 同 XInfoStream，使用 ctx
+func (c *Cacher) XInfoStreamCtx(ctx context.Context, stream string) (*XInfoStream, error) {
+	return c.withCtx(ctx).XInfoStream(stream)
+}
+
+// XInfoGroupsCtx 同 XInfoGroups，使用 ctx
+func (c *Cacher) XInfoGroupsCtx(ctx context.Context, stream string) ([]XInfoGroup, error) {
+	return c.withCtx(ctx).XInfoGroups(stream)
+}
+
+// XInfoConsumersCtx 同 XInfoConsumers，使用 ctx
+func (c *Cacher) XInfoConsumersCtx(ctx context.Context, stream, group string) ([]XInfoConsumer, error) {
+	return c.withCtx(ctx).XInfoConsumers(stream, group)
+}
+
+// 發布訂閱
+
+// PublishCtx 同 Publish，使用 ctx
+func (c *Cacher) PublishCtx(ctx context.Context, channel, message string) (int64, error) {
+	return c.withCtx(ctx).Publish(channel, message)
+}
+
+// PubSubChannelsCtx 同 PubSubChannels，使用 ctx
+func (c *Cacher) PubSubChannelsCtx(ctx context.Context, pattern string) ([]string, error) {
+	return c.withCtx(ctx).PubSubChannels(pattern)
+}
+
+// PubSubNumSubCtx 同 PubSubNumSub，使用 ctx
+func (c *Cacher) PubSubNumSubCtx(ctx context.Context, channels ...string) (map[string]int64, error) {
+	return c.withCtx(ctx).PubSubNumSub(channels...)
+}
+
+// SubscribeCtx 同 Subscribe，使用 ctx
+func (c *Cacher) SubscribeCtx(ctx context.Context, onMessage func(channel string, data []byte) error, channels ...string) (*Subscription, error) {
+	return c.withCtx(ctx).Subscribe(onMessage, channels...)
+}
+
+// SubscribeWithOptionsCtx 同 SubscribeWithOptions，使用 ctx
+func (c *Cacher) SubscribeWithOptionsCtx(ctx context.Context, opts SubscribeOptions, onMessage func(channel string, data []byte) error, channels ...string) (*Subscription, error) {
+	return c.withCtx(ctx).SubscribeWithOptions(opts, onMessage, channels...)
+}
+
+// PSubscribeCtx 同 PSubscribe，使用 ctx
+func (c *Cacher) PSubscribeCtx(ctx context.Context, onMessage func(pattern, channel string, data []byte) error, patterns ...string) (*Subscription, error) {
+	return c.withCtx(ctx).PSubscribe(onMessage, patterns...)
+}
+
+// PSubscribeWithOptionsCtx 同 PSubscribeWithOptions，使用 ctx
+func (c *Cacher) PSubscribeWithOptionsCtx(ctx context.Context, opts SubscribeOptions, onMessage func(pattern, channel string, data []byte) error, patterns ...string) (*Subscription, error) {
+	return c.withCtx(ctx).PSubscribeWithOptions(opts, onMessage, patterns...)
+}
+
+// 腳本及鎖
+
+// DoScriptCtx 同 DoScript，使用 ctx
+func (s *Script) DoScriptCtx(ctx context.Context, c *Cacher, keysAndArgs ...interface{}) (interface{}, error) {
+	return s.DoScript(c.withCtx(ctx), keysAndArgs...)
+}
+
+// LockCtx 同 Lock，ctx 取消時停止重試取得鎖
+func (m *Mutex) LockCtx(ctx context.Context) error {
+	return m.lock(m.cacher.withCtx(ctx))
+}
+
+// UnLockCtx 同 UnLock，使用 ctx
+func (m *Mutex) UnLockCtx(ctx context.Context) (bool, error) {
+	return m.unlock(m.cacher.withCtx(ctx))
+}
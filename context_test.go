@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacher_Ctx(t *testing.T) {
+	c, _ := newTestCacher(t)
+
+	if err := c.SetCtx(context.Background(), "ctx-T1", "a", 0).Err; err != nil {
+		t.Fatalf("SetCtx() error = %v", err)
+	}
+	if got, err := c.GetCtx(context.Background(), "ctx-T1").String(); err != nil || got != "a" {
+		t.Errorf("GetCtx() = %q, %v, want a", got, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.GetCtx(ctx, "ctx-T1").Err; err != context.Canceled {
+		t.Errorf("GetCtx() with canceled ctx error = %v, want context.Canceled", err)
+	}
+	if _, err := c.ScriptLoadCtx(ctx, "return 1"); err != context.Canceled {
+		t.Errorf("ScriptLoadCtx() error = %v, want context.Canceled", err)
+	}
+	if err := c.NewMutex("ctx-lock").LockCtx(ctx); err == nil {
+		t.Errorf("LockCtx() with canceled ctx error = nil")
+	}
+}
+
+func TestCacher_BLPopCtxDeadline(t *testing.T) {
+	c, _ := newTestCacher(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := c.BLPopCtx(ctx, "ctx-list", 5).Err; err == nil {
+		t.Errorf("BLPopCtx() error = nil, want deadline error")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("BLPopCtx() took %v, want to stop at ctx deadline", d)
+	}
+}
+
+func TestCacher_SubscribeCtx(t *testing.T) {
+	c, _ := newTestCacher(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := c.SubscribeCtx(ctx, func(string, []byte) error { return nil }, "ctx-ch")
+	if err != nil {
+		t.Fatalf("SubscribeCtx() error = %v", err)
+	}
+	cancel()
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		t.Errorf("subscription not closed after ctx canceled")
+	}
+}
+
+func TestCacher_withCtx(t *testing.T) {
+	c, _ := newTestCacher(t)
+	ctx := context.WithValue(context.Background(), "traceID", "t-1")
+
+	clone := c.WithContext(context.Background(), "traceID").withCtx(ctx)
+	if clone.ctx.Field != "traceID" || clone.ctx.Context != ctx {
+		t.Errorf("withCtx() ctx = %+v", clone.ctx)
+	}
+	if c.ctx.Context != nil {
+		t.Errorf("withCtx() modified the original Cacher")
+	}
+}
+
+func TestCacher_CtxNil(t *testing.T) {
+	c, _ := newTestCacher(t)
+
+	defer func() {
+		if r := recover(); r != "nil context" {
+			t.Errorf("GetCtx(nil) recover = %v, want nil context", r)
+		}
+	}()
+	c.GetCtx(nil, "ctx-T2")
+}
@@ -0,0 +1,174 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+/**
+延遲佇列：工作以執行時間(unix 毫秒)作為分數存放在有序集合，
+poller 定期用lua腳本把到期的工作原子性的搬到可靠佇列，多個 poller 同時執行也只會搬移一次。
+**/
+
+// delayedPromoteScript 將到期的工作搬到佇列
+// KEYS[1] 延遲有序集合 KEYS[2] 佇列 ARGV[1] 現在時間(毫秒) ARGV[2] 數量上限
+var delayedPromoteScript = NewScript(2, `
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, item in ipairs(items) do
+	redis.call('ZREM', KEYS[1], item)
+	redis.call('LPUSH', KEYS[2], item)
+end
+return #items
+`)
+
+// delayedRetryScript 將處理中的工作移到延遲有序集合
+// KEYS[1] processing KEYS[2] inflight KEYS[3] owners KEYS[4] 延遲有序集合 ARGV[1] 工作 ARGV[2] 重試的工作 ARGV[3] 執行時間(毫秒)
+var delayedRetryScript = NewScript(4, `
+local removed = redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+if removed > 0 then
+	redis.call('ZADD', KEYS[4], ARGV[3], ARGV[2])
+end
+return removed
+`)
+
+// DelayedQueueOptions 延遲佇列設定
+type DelayedQueueOptions struct {
+	PollInterval time.Duration   // 檢查到期工作的間隔，預設1秒
+	BatchSize    int64           // 每次最多搬移的數量，預設100
+	OnError      func(err error) // Run 的 Promote 失敗時呼叫，未設定時寫到 Logger
+}
+
+// DelayedQueue 延遲佇列，到期的工作會被搬到對應的 ReliableQueue
+type DelayedQueue struct {
+	cacher *Cacher
+	queue  *ReliableQueue
+	opts   DelayedQueueOptions
+}
+
+// NewDelayedQueue 產生新的延遲佇列，到期的工作會被放到 queue，指令以 c 執行
+func (c *Cacher) NewDelayedQueue(queue *ReliableQueue, opts DelayedQueueOptions) *DelayedQueue {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	return &DelayedQueue{
+		cacher: c,
+		queue:  queue,
+		opts:   opts,
+	}
+}
+
+// PushAt 加入在 at 時間執行的工作
+func (d *DelayedQueue) PushAt(at time.Time, payload interface{}) *Cmd {
+	raw, err := d.queue.newJob(payload)
+	if err != nil {
+		return &Cmd{
+			Err: err,
+		}
+	}
+
+	return d.schedule(at, raw)
+}
+
+// PushAfter 加入在 delay 時間後執行的工作
+func (d *DelayedQueue) PushAfter(delay time.Duration, payload interface{}) *Cmd {
+	return d.PushAt(time.Now().Add(delay), payload)
+}
+
+// Retry 將處理失敗的工作在 delay 時間後重新放回佇列，Attempts 會加一並紀錄錯誤訊息
+func (d *DelayedQueue) Retry(job *Job, delay time.Duration, cause error) error {
+	if job == nil || job.raw == "" {
+		return ErrJobNotInFlight
+	}
+	retry := *job
+	retry.Attempts++
+	if cause != nil {
+		retry.LastError = cause.Error()
+	}
+	raw, err := json.Marshal(&retry)
+	if err != nil {
+		return err
+	}
+
+	q := d.queue
+	removed, err := Int64(delayedRetryScript.DoScript(d.cacher,
+		d.cacher.getKey(q.processingKey(job.consumer)),
+		d.cacher.getKey(q.inflightKey()),
+		d.cacher.getKey(q.ownersKey()),
+		d.cacher.getKey(d.key()),
+		job.raw, string(raw),
+		time.Now().Add(delay).UnixNano()/int64(time.Millisecond),
+	))
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrJobNotInFlight
+	}
+
+	return nil
+}
+
+// Promote 將到期的工作搬到佇列，返回搬移的數量
+func (d *DelayedQueue) Promote() (int64, error) {
+	return Int64(delayedPromoteScript.DoScript(d.cacher,
+		d.cacher.getKey(d.key()),
+		d.cacher.getKey(d.queue.readyKey()),
+		time.Now().UnixNano()/int64(time.Millisecond),
+		d.opts.BatchSize,
+	))
+}
+
+// Run 每隔 PollInterval 搬移一次到期工作直到 ctx 結束，一次搬滿 BatchSize 時會立即再搬一次，失敗時交給 OnError
+func (d *DelayedQueue) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		n, err := d.Promote()
+		if err != nil {
+			d.reportError(err)
+		}
+		if err == nil && n >= d.opts.BatchSize {
+			timer.Reset(0)
+			continue
+		}
+		timer.Reset(d.opts.PollInterval)
+	}
+}
+
+// Len 尚未到期的工作數量
+func (d *DelayedQueue) Len() *Cmd {
+	return d.cacher.ZCard(d.key())
+}
+
+// reportError 交給 OnError，未設定時寫到 Logger
+func (d *DelayedQueue) reportError(err error) {
+	if d.opts.OnError != nil {
+		d.opts.OnError(err)
+		return
+	}
+	if logger := d.cacher.getLogger(); logger != nil {
+		logger.Error("delayed queue promote failed", "queue", d.queue.name, "error", err)
+	}
+}
+
+func (d *DelayedQueue) schedule(at time.Time, raw string) *Cmd {
+	return d.cacher.ZAddFloat(d.key(), float64(at.UnixNano()/int64(time.Millisecond)), raw)
+}
+
+func (d *DelayedQueue) key() string {
+	return d.queue.readyKey() + ":delayed"
+}
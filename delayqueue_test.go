@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDelayedQueue_Promote(t *testing.T) {
+	c, _ := newTestCacher(t)
+	q := c.NewReliableQueue("delayed-T1", ReliableQueueOptions{})
+	d := c.NewDelayedQueue(q, DelayedQueueOptions{})
+
+	if cmd := d.PushAt(time.Now().Add(-time.Second), "due"); cmd.Err != nil {
+		t.Fatalf("PushAt() error = %v", cmd.Err)
+	}
+	if cmd := d.PushAfter(time.Hour, "later"); cmd.Err != nil {
+		t.Fatalf("PushAfter() error = %v", cmd.Err)
+	}
+
+	n, err := d.Promote()
+	if err != nil {
+		t.Fatalf("Promote() error = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Promote() = %d, want 1", n)
+	}
+	if l, _ := d.Len().Int(); l != 1 {
+		t.Errorf("Len() = %d, want 1", l)
+	}
+
+	job, err := q.Pop("worker-1", 1)
+	if err != nil {
+		t.Fatalf("Pop() error = %v", err)
+	}
+	if job.Body != "due" {
+		t.Errorf("Pop() body = %s, want due", job.Body)
+	}
+}
+
+func TestDelayedQueue_ConcurrentPromote(t *testing.T) {
+	c, _ := newTestCacher(t)
+	q := c.NewReliableQueue("delayed-T2", ReliableQueueOptions{})
+	d := c.NewDelayedQueue(q, DelayedQueueOptions{BatchSize: 3})
+
+	for i := 0; i < 20; i++ {
+		d.PushAt(time.Now().Add(-time.Second), i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.Run(ctx)
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if l, _ := d.Len().Int(); l == 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	if l, _ := q.Len().Int(); l != 20 {
+		t.Errorf("queue Len() = %d, want 20", l)
+	}
+}
+
+func TestDelayedQueue_RunError(t *testing.T) {
+	c, _ := newTestCacher(t)
+	q := c.NewReliableQueue("delayed-T3", ReliableQueueOptions{})
+	errs := make(chan error, 1)
+	d := c.NewDelayedQueue(q, DelayedQueueOptions{
+		PollInterval: time.Millisecond,
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	c.pool.AddHook(&cmdFailHook{name: "eval", n: math.MaxInt32})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	select {
+	case err := <-errs:
+		if err.Error() != "injected" {
+			t.Errorf("OnError() error = %v, want injected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnError not called")
+	}
+}
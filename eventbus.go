@@ -0,0 +1,291 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// ErrUnknownEvent 收到未註冊的事件
+var ErrUnknownEvent = errors.New("eventbus: unknown event")
+
+// HandlerErrors 同一個事件有多個處理函式失敗時的錯誤
+type HandlerErrors []error
+
+func (e HandlerErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "eventbus: " + strings.Join(msgs, "; ")
+}
+
+// Codec 事件的編碼方式
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec 以 encoding/json 編碼
+type JSONCodec struct{}
+
+// Marshal 編碼
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal 解碼
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// EventEnvelope 事件的外層資訊，Data 為以 Codec 編碼後的事件內容。
+// 發布時以 otel.GetTextMapPropagator 將 span context 寫入 Headers(W3C 為 traceparent、tracestate)，
+// 收到後再取出作為處理函式 ctx 的父 span。
+type EventEnvelope struct {
+	Type      string            `json:"type"`
+	ID        string            `json:"id"`
+	Timestamp time.Time         `json:"timestamp"`
+	TraceID   string            `json:"trace_id,omitempty"` // 發布時 WithContext field 的值，舊版的追蹤方式，僅供參考
+	Headers   map[string]string `json:"headers,omitempty"`
+	Data      []byte            `json:"data"`
+}
+
+// EventBusOptions EventBus 設定
+type EventBusOptions struct {
+	Channel   string           // 頻道名稱前綴，事件發布到 Channel + 事件名稱，預設 "events:"
+	Codec     Codec            // 預設 JSONCodec
+	Subscribe SubscribeOptions // Listen 的派送方式及錯誤回呼
+}
+
+// EventBus 以發布訂閱傳遞有型別的事件，事件名稱需先以 Register 註冊對應的 Go 型別
+type EventBus struct {
+	cacher *Cacher
+	opts   EventBusOptions
+	reg    *eventRegistry
+}
+
+// eventRegistry 事件型別及處理函式，WithContext 產生的 EventBus 共用
+type eventRegistry struct {
+	mu       sync.RWMutex
+	types    map[string]reflect.Type
+	handlers map[string][]reflect.Value
+}
+
+var (
+	contextType  = reflect.TypeOf((*context.Context)(nil)).Elem()
+	envelopeType = reflect.TypeOf((*EventEnvelope)(nil))
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// NewEventBus 產生新的 EventBus
+func (c *Cacher) NewEventBus(opts EventBusOptions) *EventBus {
+	if opts.Channel == "" {
+		opts.Channel = "events:"
+	}
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec{}
+	}
+
+	return &EventBus{
+		cacher: c,
+		opts:   opts,
+		reg: &eventRegistry{
+			types:    make(map[string]reflect.Type),
+			handlers: make(map[string][]reflect.Value),
+		},
+	}
+}
+
+// WithContext 產生使用 Cacher.WithContext 的 EventBus，發布的事件會帶上 ctx 的 span context 及 field 的值(TraceID)，
+// Listen 時處理函式的 ctx 以此 ctx 為基礎
+func (b *EventBus) WithContext(ctx context.Context, field string) *EventBus {
+	clone := *b
+	clone.cacher = b.cacher.WithContext(ctx, field)
+
+	return &clone
+}
+
+// Register 註冊事件名稱對應的型別，sample 可以是值或指標，例如 Register("order.created", OrderCreated{})
+func (b *EventBus) Register(name string, sample interface{}) {
+	t := reflect.TypeOf(sample)
+	if t == nil {
+		panic("eventbus: nil sample")
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	b.reg.mu.Lock()
+	b.reg.types[name] = t
+	b.reg.mu.Unlock()
+}
+
+// On 註冊事件的處理函式，需在 Listen 之前呼叫。
+// handler 的型別為 func(ctx context.Context, env *EventEnvelope, event T) error，
+// T 為註冊的型別或其指標，型別不符時 panic。
+func (b *EventBus) On(name string, handler interface{}) {
+	b.reg.mu.Lock()
+	defer b.reg.mu.Unlock()
+
+	t, ok := b.reg.types[name]
+	if !ok {
+		panic(fmt.Sprintf("eventbus: event %q not registered", name))
+	}
+	fn := reflect.ValueOf(handler)
+	ft := fn.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != 3 || ft.NumOut() != 1 ||
+		ft.In(0) != contextType || ft.In(1) != envelopeType ||
+		(ft.In(2) != t && ft.In(2) != reflect.PtrTo(t)) || ft.Out(0) != errorType {
+		panic(fmt.Sprintf("eventbus: handler for %q must be func(context.Context, *EventEnvelope, %s) error", name, t))
+	}
+
+	b.reg.handlers[name] = append(b.reg.handlers[name], fn)
+}
+
+// Publish 發布事件，返回接收到事件的訂閱者數量
+func (b *EventBus) Publish(name string, event interface{}) (int64, error) {
+	return b.PublishWithHeaders(name, event, nil)
+}
+
+// PublishWithHeaders 發布事件並附加自訂的標頭
+func (b *EventBus) PublishWithHeaders(name string, event interface{}, headers map[string]string) (int64, error) {
+	b.reg.mu.RLock()
+	_, ok := b.reg.types[name]
+	b.reg.mu.RUnlock()
+	if !ok {
+		return 0, ErrUnknownEvent
+	}
+
+	data, err := b.opts.Codec.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	id, err := randomToken()
+	if err != nil {
+		return 0, err
+	}
+	env := &EventEnvelope{
+		Type:      name,
+		ID:        id,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	// 複製一份再寫入追蹤標頭，不修改呼叫端的 headers
+	carrier := make(headerCarrier, len(headers))
+	for k, v := range headers {
+		carrier[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(b.context(), carrier)
+	if len(carrier) > 0 {
+		env.Headers = carrier
+	}
+	if ctx := b.cacher.ctx; ctx.Context != nil {
+		if traceID := ctx.Context.Value(ctx.Field); traceID != nil {
+			env.TraceID = fmt.Sprint(traceID)
+		}
+	}
+
+	message, err := b.opts.Codec.Marshal(env)
+	if err != nil {
+		return 0, err
+	}
+
+	return b.cacher.Publish(b.opts.Channel+name, string(message))
+}
+
+// Listen 訂閱所有已註冊處理函式的事件，解碼或處理失敗的錯誤交給 Subscribe.OnError
+func (b *EventBus) Listen() (*Subscription, error) {
+	b.reg.mu.RLock()
+	channels := make([]string, 0, len(b.reg.handlers))
+	for name := range b.reg.handlers {
+		channels = append(channels, b.opts.Channel+name)
+	}
+	b.reg.mu.RUnlock()
+
+	return b.cacher.SubscribeWithOptions(b.opts.Subscribe, b.dispatch, channels...)
+}
+
+// dispatch 解碼事件並依序呼叫所有處理函式，每個處理函式收到各自解碼的事件及 EventEnvelope 的副本，
+// 有處理函式失敗時仍會呼叫其他的，多個失敗時返回 HandlerErrors
+func (b *EventBus) dispatch(channel string, data []byte) error {
+	env := &EventEnvelope{}
+	if err := b.opts.Codec.Unmarshal(data, env); err != nil {
+		return err
+	}
+
+	b.reg.mu.RLock()
+	t, ok := b.reg.types[env.Type]
+	handlers := b.reg.handlers[env.Type]
+	b.reg.mu.RUnlock()
+	if !ok {
+		return ErrUnknownEvent
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(b.context(), headerCarrier(env.Headers))
+	var errs HandlerErrors
+	for _, fn := range handlers {
+		event := reflect.New(t)
+		if err := b.opts.Codec.Unmarshal(env.Data, event.Interface()); err != nil {
+			return err
+		}
+		if fn.Type().In(2) == t {
+			event = event.Elem()
+		}
+		e := *env
+		if err := callEventHandler(ctx, fn, &e, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return errs
+	}
+}
+
+// callEventHandler 執行處理函式，panic 視為錯誤
+func callEventHandler(ctx context.Context, fn reflect.Value, env *EventEnvelope, event reflect.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("eventbus: panic: %v", r)
+		}
+	}()
+
+	out := fn.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(env), event})
+	err, _ = out[0].Interface().(error)
+
+	return err
+}
+
+// context 取得 WithContext 傳入的 context，沒有時返回 context.Background()
+func (b *EventBus) context() context.Context {
+	if b.cacher.ctx.Context != nil {
+		return b.cacher.ctx.Context
+	}
+	return context.Background()
+}
+
+// headerCarrier 以 EventEnvelope.Headers 傳遞追蹤資訊
+type headerCarrier map[string]string
+
+// Get 取得標頭
+func (h headerCarrier) Get(key string) string {
+	return h[key]
+}
+
+// Set 設定標頭
+func (h headerCarrier) Set(key, value string) {
+	h[key] = value
+}
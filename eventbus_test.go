@@ -0,0 +1,168 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type orderCreated struct {
+	OrderID int     `json:"order_id"`
+	Amount  float64 `json:"amount"`
+}
+
+// contextSpan 指定 SpanContext 的 span
+type contextSpan struct {
+	trace.Span
+	sc trace.SpanContext
+}
+
+func (s contextSpan) SpanContext() trace.SpanContext { return s.sc }
+
+func TestEventBus_PublishListen(t *testing.T) {
+	c, _ := newTestCacher(t)
+	bus := c.NewEventBus(EventBusOptions{})
+	bus.Register("order.created", orderCreated{})
+
+	type received struct {
+		env   *EventEnvelope
+		event orderCreated
+		span  trace.SpanContext
+	}
+	got := make(chan received, 2)
+	bus.On("order.created", func(ctx context.Context, env *EventEnvelope, e *orderCreated) error {
+		got <- received{env, *e, trace.RemoteSpanContextFromContext(ctx)}
+		return nil
+	})
+	bus.On("order.created", func(ctx context.Context, env *EventEnvelope, e orderCreated) error {
+		got <- received{env, e, trace.RemoteSpanContextFromContext(ctx)}
+		return nil
+	})
+
+	sub, err := bus.WithContext(context.Background(), "traceID").Listen()
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer sub.Close()
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	sc := trace.SpanContext{
+		TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	}
+	ctx := trace.ContextWithSpan(context.Background(), contextSpan{trace.SpanFromContext(context.Background()), sc})
+	ctx = context.WithValue(ctx, "traceID", "trace-1")
+	n, err := bus.WithContext(ctx, "traceID").Publish("order.created", orderCreated{OrderID: 7, Amount: 9.5})
+	if err != nil || n != 1 {
+		t.Fatalf("Publish() = %d, %v, want 1", n, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-got:
+			if r.event != (orderCreated{OrderID: 7, Amount: 9.5}) {
+				t.Errorf("event = %+v", r.event)
+			}
+			if r.env.Type != "order.created" || r.env.ID == "" || r.env.Timestamp.IsZero() || r.env.TraceID != "trace-1" {
+				t.Errorf("envelope = %+v", r.env)
+			}
+			if want := "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01"; r.env.Headers["traceparent"] != want {
+				t.Errorf("traceparent = %q, want %q", r.env.Headers["traceparent"], want)
+			}
+			if r.span.TraceID != sc.TraceID || r.span.SpanID != sc.SpanID {
+				t.Errorf("ctx span = %+v, want %+v", r.span, sc)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("handler %d not called", i)
+		}
+	}
+}
+
+func TestEventBus_Errors(t *testing.T) {
+	c, _ := newTestCacher(t)
+	bus := c.NewEventBus(EventBusOptions{})
+
+	if _, err := bus.Publish("order.created", orderCreated{}); err != ErrUnknownEvent {
+		t.Errorf("Publish() unregistered error = %v, want %v", err, ErrUnknownEvent)
+	}
+
+	bus.Register("order.created", &orderCreated{})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("On() with wrong handler type did not panic")
+			}
+		}()
+		bus.On("order.created", func(ctx context.Context, env *EventEnvelope, e string) error { return nil })
+	}()
+
+	bus.On("order.created", func(ctx context.Context, env *EventEnvelope, e *orderCreated) error {
+		return errors.New("failed")
+	})
+	sub, err := bus.Listen()
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer sub.Close()
+	errs := make(chan error, 1)
+	sub.OnError(func(channel string, err error) {
+		errs <- err
+	})
+
+	if n, err := bus.Publish("order.created", orderCreated{OrderID: 1}); err != nil || n != 1 {
+		t.Fatalf("Publish() = %d, %v, want 1", n, err)
+	}
+	select {
+	case err := <-errs:
+		if err.Error() != "failed" {
+			t.Errorf("OnError() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("OnError() not called")
+	}
+}
+
+func TestEventBus_DispatchAllHandlers(t *testing.T) {
+	c, _ := newTestCacher(t)
+	bus := c.NewEventBus(EventBusOptions{})
+	bus.Register("order.created", &orderCreated{})
+
+	var got orderCreated
+	bus.On("order.created", func(ctx context.Context, env *EventEnvelope, e *orderCreated) error {
+		e.OrderID = 0
+		env.Type = "changed"
+		return errors.New("first")
+	})
+	bus.On("order.created", func(ctx context.Context, env *EventEnvelope, e *orderCreated) error {
+		panic("boom")
+	})
+	bus.On("order.created", func(ctx context.Context, env *EventEnvelope, e orderCreated) error {
+		if env.Type != "order.created" {
+			t.Errorf("env.Type = %s, want order.created", env.Type)
+		}
+		got = e
+		return nil
+	})
+
+	data, _ := json.Marshal(orderCreated{OrderID: 3})
+	message, _ := json.Marshal(&EventEnvelope{Type: "order.created", Data: data})
+	err := bus.dispatch("events:order.created", message)
+
+	errs, ok := err.(HandlerErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("dispatch() error = %v, want 2 HandlerErrors", err)
+	}
+	if errs[0].Error() != "first" || errs[1].Error() != "eventbus: panic: boom" {
+		t.Errorf("dispatch() errors = %v", errs)
+	}
+	if got.OrderID != 3 {
+		t.Errorf("third handler event = %+v, want OrderID 3", got)
+	}
+}
@@ -0,0 +1,403 @@
+package redis
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// errWrongType 記憶體中的鍵型別不符
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// FallbackOptions Redis 無法使用時的記憶體降級設定
+type FallbackOptions struct {
+	MaxEntries    int           // 記憶體中最多保留的鍵數，超過時淘汰最久未使用的鍵，預設10000
+	DefaultTTL    time.Duration // 沒有過期時間的鍵在記憶體中保留的時間，預設5分鐘，小於0時不過期
+	ReplayWrites  bool          // 降級期間的寫入在恢復後依序重送到 Redis
+	MaxReplay     int           // 等待重送的寫入上限，超過時丟棄最舊的，預設1000
+	CheckInterval time.Duration // 降級時檢查 Redis 是否恢復的間隔，預設1秒
+	// OnChange 進入或離開降級模式時呼叫
+	OnChange func(event FallbackEvent)
+}
+
+// FallbackEvent 降級狀態改變的事件
+type FallbackEvent struct {
+	Degraded bool      // true 為進入降級，false 為恢復
+	Err      error     // 進入降級的原因
+	Replayed int       // 恢復時重送成功的寫入數
+	Dropped  int       // 恢復時重送失敗及超過上限被丟棄的寫入數
+	Time     time.Time // 發生時間
+}
+
+// fallbackCommands 降級時由記憶體處理的指令
+var fallbackCommands = map[string]bool{
+	"GET": true, "SET": true, "SETEX": true, "DEL": true, "HGET": true, "HSET": true, "EXPIRE": true,
+}
+
+// EnableFallback Redis 連不上或斷路器開啟時，Get/Set/Del/HGet/HSet/Expire 改用有容量上限的記憶體儲存，
+// 並在背景檢查 Redis 是否恢復，GracefulStop 時停止
+func (c *Cacher) EnableFallback(opts FallbackOptions) {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 10000
+	}
+	if opts.DefaultTTL == 0 {
+		opts.DefaultTTL = 5 * time.Minute
+	}
+	if opts.MaxReplay <= 0 {
+		opts.MaxReplay = 1000
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = time.Second
+	}
+
+	f := &fallbackStore{
+		cacher:  c,
+		opts:    opts,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	c.fallback = f
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.onStop("fallback checker", func() {
+		cancel()
+		<-done
+	})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(opts.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if f.Degraded() {
+					f.check(ctx)
+				}
+			}
+		}
+	}()
+}
+
+// Degraded 是否處於降級模式，未啟用 EnableFallback 時為 false
+func (c *Cacher) Degraded() bool {
+	return c.fallback != nil && c.fallback.Degraded()
+}
+
+// fallbackDo 降級中或 Redis 無法使用時以記憶體處理指令，返回 false 表示仍使用 Redis 的結果
+func (c *Cacher) fallbackDo(args []interface{}, err error) (*Cmd, bool) {
+	if c.fallback == nil {
+		return nil, false
+	}
+	if err != nil {
+		if !unavailableError(err) {
+			return nil, false
+		}
+		c.fallback.degrade(err)
+	}
+	if !c.fallback.Degraded() || !fallbackCommands[strings.ToUpper(formatArg(args[0]))] {
+		return nil, false
+	}
+
+	val, err := c.fallback.do(args)
+	return &Cmd{val: val, Err: err, Fallback: true}, true
+}
+
+// unavailableError Redis 無法連線的錯誤
+func unavailableError(err error) bool {
+	switch err {
+	case ErrCircuitOpen, io.EOF, io.ErrUnexpectedEOF, redis.ErrClosed:
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return err.Error() == "redis: connection pool timeout"
+}
+
+type fallbackEntry struct {
+	key      string
+	str      *string
+	hash     map[string]string
+	expireAt time.Time
+}
+
+// fallbackStore 降級時使用的記憶體儲存
+type fallbackStore struct {
+	cacher *Cacher
+	opts   FallbackOptions
+
+	mu       sync.Mutex
+	degraded bool
+	entries  map[string]*list.Element
+	lru      *list.List
+	replay   []fallbackWrite
+	dropped  int
+}
+
+// fallbackWrite 等待重送的寫入
+type fallbackWrite struct {
+	args     []interface{}
+	expireAt time.Time // SET 在記憶體中的過期時間，重送時以剩餘時間加上 PX
+}
+
+// Degraded 是否處於降級模式
+func (f *fallbackStore) Degraded() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.degraded
+}
+
+func (f *fallbackStore) degrade(err error) {
+	f.mu.Lock()
+	if f.degraded {
+		f.mu.Unlock()
+		return
+	}
+	f.degraded = true
+	f.mu.Unlock()
+
+	if logger := f.cacher.getLogger(); logger != nil {
+		logger.Warn("redis unavailable, using in-memory fallback", "error", err)
+	}
+	f.emit(FallbackEvent{Degraded: true, Err: err, Time: time.Now()})
+}
+
+// check 以 PING 檢查 Redis，恢復時重送寫入並清空記憶體。
+// 重送經過 Do 的重試、逾時及 hook，但不再進入降級
+func (f *fallbackStore) check(ctx context.Context) {
+	if err := f.cacher.pool.Ping(ctx).Err(); err != nil {
+		return
+	}
+
+	f.mu.Lock()
+	replay := f.replay
+	dropped := f.dropped
+	f.replay = nil
+	f.dropped = 0
+	f.mu.Unlock()
+
+	direct := f.cacher.clone()
+	direct.fallback = nil
+	direct.ctx = ContextTraceInfo{Context: ctx}
+	replayed := 0
+	for i, w := range replay {
+		args := w.args
+		if !w.expireAt.IsZero() {
+			remaining := time.Until(w.expireAt)
+			if remaining <= 0 {
+				// 在記憶體中已過期，不重送
+				continue
+			}
+			ms := int64(remaining / time.Millisecond)
+			if ms < 1 {
+				ms = 1
+			}
+			args = append(args[:len(args):len(args)], "PX", ms)
+		}
+		err := direct.Do(formatArg(args[0]), args[1:]...).Err
+		if err != nil && unavailableError(err) {
+			// 又斷線，剩下的寫入留到下次
+			f.mu.Lock()
+			f.replay = append(replay[i:len(replay):len(replay)], f.replay...)
+			f.dropped += dropped
+			f.mu.Unlock()
+			return
+		}
+		if err != nil && err != ErrNil {
+			dropped++
+			continue
+		}
+		replayed++
+	}
+
+	f.mu.Lock()
+	if len(f.replay) > 0 {
+		// 重送期間仍有寫入進到記憶體，下次再重送
+		f.dropped += dropped
+		f.mu.Unlock()
+		return
+	}
+	f.degraded = false
+	f.entries = make(map[string]*list.Element)
+	f.lru.Init()
+	f.mu.Unlock()
+
+	if logger := f.cacher.getLogger(); logger != nil {
+		logger.Info("redis recovered", "replayed", replayed, "dropped", dropped)
+	}
+	f.emit(FallbackEvent{Replayed: replayed, Dropped: dropped, Time: time.Now()})
+}
+
+func (f *fallbackStore) emit(event FallbackEvent) {
+	if f.opts.OnChange != nil {
+		f.opts.OnChange(event)
+	}
+}
+
+// do 以記憶體執行指令，語意與 Redis 相同
+func (f *fallbackStore) do(args []interface{}) (interface{}, error) {
+	name := strings.ToUpper(formatArg(args[0]))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var val interface{}
+	var err error
+	var expireAt time.Time
+	write := true
+	switch {
+	case name == "GET" && len(args) == 2:
+		write = false
+		val, err = f.get(formatArg(args[1]))
+	case name == "SET" && len(args) == 3:
+		f.set(formatArg(args[1]), formatArg(args[2]), f.opts.DefaultTTL)
+		if f.opts.DefaultTTL > 0 {
+			expireAt = time.Now().Add(f.opts.DefaultTTL)
+		}
+		val = "OK"
+	case name == "SETEX" && len(args) == 4:
+		seconds, _ := strconv.ParseInt(formatArg(args[2]), 10, 64)
+		f.set(formatArg(args[1]), formatArg(args[3]), time.Duration(seconds)*time.Second)
+		val = "OK"
+	case name == "DEL" && len(args) >= 2:
+		var n int64
+		for _, key := range args[1:] {
+			if f.lookup(formatArg(key)) != nil {
+				f.remove(formatArg(key))
+				n++
+			}
+		}
+		val = n
+	case name == "HGET" && len(args) == 3:
+		write = false
+		val, err = f.hget(formatArg(args[1]), formatArg(args[2]))
+	case name == "HSET" && len(args) >= 4 && len(args)%2 == 0:
+		val, err = f.hset(formatArg(args[1]), args[2:])
+	case name == "EXPIRE" && len(args) == 3:
+		seconds, _ := strconv.ParseInt(formatArg(args[2]), 10, 64)
+		val = f.expire(formatArg(args[1]), time.Duration(seconds)*time.Second)
+	default:
+		return nil, errors.New("fallback: unsupported command " + name)
+	}
+
+	if write && err == nil && f.opts.ReplayWrites {
+		f.queue(args, expireAt)
+	}
+	return val, err
+}
+
+func (f *fallbackStore) queue(args []interface{}, expireAt time.Time) {
+	if len(f.replay) >= f.opts.MaxReplay {
+		f.replay = f.replay[1:]
+		f.dropped++
+	}
+	f.replay = append(f.replay, fallbackWrite{args: append([]interface{}(nil), args...), expireAt: expireAt})
+}
+
+// lookup 取得未過期的鍵並更新使用順序
+func (f *fallbackStore) lookup(key string) *fallbackEntry {
+	el, ok := f.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*fallbackEntry)
+	if !e.expireAt.IsZero() && !time.Now().Before(e.expireAt) {
+		f.remove(key)
+		return nil
+	}
+	f.lru.MoveToFront(el)
+	return e
+}
+
+// store 新增或取代鍵，超過容量時淘汰最久未使用的鍵
+func (f *fallbackStore) store(e *fallbackEntry, ttl time.Duration) {
+	if ttl > 0 {
+		e.expireAt = time.Now().Add(ttl)
+	}
+	f.remove(e.key)
+	f.entries[e.key] = f.lru.PushFront(e)
+	for f.lru.Len() > f.opts.MaxEntries {
+		f.remove(f.lru.Back().Value.(*fallbackEntry).key)
+	}
+}
+
+func (f *fallbackStore) remove(key string) {
+	if el, ok := f.entries[key]; ok {
+		f.lru.Remove(el)
+		delete(f.entries, key)
+	}
+}
+
+func (f *fallbackStore) get(key string) (interface{}, error) {
+	e := f.lookup(key)
+	switch {
+	case e == nil:
+		return nil, ErrNil
+	case e.str == nil:
+		return nil, errWrongType
+	}
+	return *e.str, nil
+}
+
+func (f *fallbackStore) set(key, value string, ttl time.Duration) {
+	f.store(&fallbackEntry{key: key, str: &value}, ttl)
+}
+
+func (f *fallbackStore) hget(key, field string) (interface{}, error) {
+	e := f.lookup(key)
+	switch {
+	case e == nil:
+		return nil, ErrNil
+	case e.hash == nil:
+		return nil, errWrongType
+	}
+	v, ok := e.hash[field]
+	if !ok {
+		return nil, ErrNil
+	}
+	return v, nil
+}
+
+func (f *fallbackStore) hset(key string, fields []interface{}) (interface{}, error) {
+	e := f.lookup(key)
+	if e == nil {
+		e = &fallbackEntry{key: key, hash: make(map[string]string)}
+		f.store(e, f.opts.DefaultTTL)
+	}
+	if e.hash == nil {
+		return nil, errWrongType
+	}
+	var n int64
+	for i := 0; i < len(fields); i += 2 {
+		field := formatArg(fields[i])
+		if _, ok := e.hash[field]; !ok {
+			n++
+		}
+		e.hash[field] = formatArg(fields[i+1])
+	}
+	return n, nil
+}
+
+func (f *fallbackStore) expire(key string, ttl time.Duration) int64 {
+	e := f.lookup(key)
+	if e == nil {
+		return 0
+	}
+	if ttl <= 0 {
+		f.remove(key)
+		return 1
+	}
+	e.expireAt = time.Now().Add(ttl)
+	return 1
+}
@@ -0,0 +1,118 @@
+package redis
+
+import (
+	"container/list"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacher_EnableFallback(t *testing.T) {
+	c, s := newTestCacher(t)
+	var mu sync.Mutex
+	var events []FallbackEvent
+	c.EnableFallback(FallbackOptions{
+		CheckInterval: 10 * time.Millisecond,
+		ReplayWrites:  true,
+		OnChange: func(e FallbackEvent) {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+		},
+	})
+
+	c.Set("fallback-T1", "a", 0)
+	s.Close()
+
+	cmd := c.Set("fallback-T2", "b", 60)
+	if cmd.Err != nil || !cmd.Fallback {
+		t.Fatalf("Set() error = %v, fallback = %v", cmd.Err, cmd.Fallback)
+	}
+	if !c.Degraded() {
+		t.Fatalf("Degraded() = false")
+	}
+	if got, err := c.Get("fallback-T2").String(); err != nil || got != "b" {
+		t.Errorf("Get() = %q, %v, want b", got, err)
+	}
+	if err := c.Get("fallback-T1").Err; err != ErrNil {
+		t.Errorf("Get() error = %v, want ErrNil", err)
+	}
+	c.Set("fallback-T5", "c", 0)
+	c.HSet("fallback-T3", "f", 1)
+	if got, err := c.HGet("fallback-T3", "f").String(); err != nil || got != "1" {
+		t.Errorf("HGet() = %q, %v, want 1", got, err)
+	}
+	if n, err := c.Del("fallback-T1").Int64(); err != nil || n != 0 {
+		t.Errorf("Del() = %d, %v, want 0", n, err)
+	}
+	if cmd := c.IncrBy("fallback-T4", 1); cmd.Err == nil || cmd.Fallback {
+		t.Errorf("IncrBy() error = %v, fallback = %v, want redis error", cmd.Err, cmd.Fallback)
+	}
+
+	if err := s.Restart(); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	// go-redis 連續撥號失敗後每秒才重試一次
+	if !waitFor(3*time.Second, func() bool { return !c.Degraded() }) {
+		t.Fatalf("still degraded after restart")
+	}
+	if got, _ := s.Get("RedisTest:fallback-T2"); got != "b" {
+		t.Errorf("replayed value = %q, want b", got)
+	}
+	// 沒有過期時間的 SET 以記憶體中剩餘的 DefaultTTL 重送
+	if got, _ := s.Get("RedisTest:fallback-T5"); got != "c" {
+		t.Errorf("replayed value = %q, want c", got)
+	}
+	if ttl := s.TTL("RedisTest:fallback-T5"); ttl <= 4*time.Minute || ttl > 5*time.Minute {
+		t.Errorf("replayed TTL = %v, want about 5m", ttl)
+	}
+	if got := s.HGet("RedisTest:fallback-T3", "f"); got != "1" {
+		t.Errorf("replayed hash = %q, want 1", got)
+	}
+	if cmd := c.Get("fallback-T1"); cmd.Fallback {
+		t.Errorf("Get() after recovery still uses fallback")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || !events[0].Degraded || events[0].Err == nil || events[1].Degraded || events[1].Replayed != 4 {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func newFallbackStore(opts FallbackOptions) *fallbackStore {
+	return &fallbackStore{opts: opts, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+func TestFallbackStore(t *testing.T) {
+	f := newFallbackStore(FallbackOptions{MaxEntries: 2, DefaultTTL: -1, MaxReplay: 2, ReplayWrites: true})
+
+	f.do([]interface{}{"SET", "a", "1"})
+	f.do([]interface{}{"SETEX", "b", 1, "2"})
+	f.do([]interface{}{"GET", "a"})
+	f.do([]interface{}{"SET", "c", "3"})
+	// b 最久未使用，被淘汰
+	if _, err := f.do([]interface{}{"GET", "b"}); err != ErrNil {
+		t.Errorf("GET b error = %v, want ErrNil", err)
+	}
+	if v, _ := f.do([]interface{}{"GET", "a"}); v != "1" {
+		t.Errorf("GET a = %v, want 1", v)
+	}
+	if _, err := f.do([]interface{}{"HGET", "a", "f"}); err != errWrongType {
+		t.Errorf("HGET error = %v, want WRONGTYPE", err)
+	}
+	if len(f.replay) != 2 || f.dropped != 1 {
+		t.Errorf("replay = %d, dropped = %d", len(f.replay), f.dropped)
+	}
+
+	f.entries["c"].Value.(*fallbackEntry).expireAt = time.Now().Add(-time.Second)
+	if _, err := f.do([]interface{}{"GET", "c"}); err != ErrNil {
+		t.Errorf("GET expired error = %v, want ErrNil", err)
+	}
+	if v, _ := f.do([]interface{}{"EXPIRE", "a", 0}); v != int64(1) {
+		t.Errorf("EXPIRE = %v, want 1", v)
+	}
+	if _, err := f.do([]interface{}{"SET", "a", "1", "NX"}); err == nil {
+		t.Errorf("SET NX error = nil, want unsupported")
+	}
+}
@@ -1,9 +1,10 @@
 module jim352261/repackageredis
 
-go 1.13
+go 1.14
 
 require (
 	github.com/alicebob/miniredis/v2 v2.14.1
 	github.com/go-redis/redis/v8 v8.4.4
 	github.com/go-redsync/redsync/v4 v4.3.0
+	go.opentelemetry.io/otel v0.15.0
 )
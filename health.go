@@ -0,0 +1,251 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthOptions 健康檢查設定
+type HealthOptions struct {
+	Interval         time.Duration // 檢查間隔，預設5秒
+	Timeout          time.Duration // 單次檢查的逾時，預設1秒
+	FailureThreshold int           // 連續失敗幾次視為斷線，預設3
+	MaxLatency       time.Duration // 延遲超過時 readiness 返回 503，0 表示不判斷
+	RequireMaster    bool          // 伺服器不是 master 時 readiness 返回 503
+}
+
+// HealthStats 健康檢查統計
+type HealthStats struct {
+	Healthy             bool          `json:"healthy"`
+	LastCheck           time.Time     `json:"last_check"`
+	LastError           string        `json:"last_error,omitempty"`
+	Latency             time.Duration `json:"latency"`     // 最近一次 PING 的往返時間
+	AvgLatency          time.Duration `json:"avg_latency"` // 往返時間的移動平均
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	Checks              int64         `json:"checks"`
+	Failures            int64         `json:"failures"`
+	DownSince           time.Time     `json:"down_since,omitempty"`
+	Role                string        `json:"role,omitempty"` // INFO replication 的 role，master 或 slave
+	ConnectedSlaves     int           `json:"connected_slaves"`
+	MasterLinkStatus    string        `json:"master_link_status,omitempty"` // slave 與 master 的連線狀態，up 或 down
+	ReplicationOffset   int64         `json:"replication_offset"`
+}
+
+// HealthChecker 背景定期 PING 及取得 INFO replication，由 EnableHealthCheck 產生
+type HealthChecker struct {
+	cacher *Cacher
+	opts   HealthOptions
+	stop   func() // 停止背景檢查並等待結束
+
+	mu          sync.RWMutex
+	stats       HealthStats
+	onLost      []func(err error)
+	onRecovered []func()
+}
+
+// EnableHealthCheck 啟動背景健康檢查，返回前會先檢查一次，GracefulStop 時停止。
+// 重複呼叫時會先停止之前的檢查，再以新的設定取代
+func (c *Cacher) EnableHealthCheck(opts HealthOptions) *HealthChecker {
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 3
+	}
+
+	if c.health != nil {
+		c.health.stop()
+	}
+	h := &HealthChecker{
+		cacher: c,
+		opts:   opts,
+		stats:  HealthStats{Healthy: true},
+	}
+	c.health = h
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.check(ctx)
+
+	done := make(chan struct{})
+	unregister := c.onStop("health checker", func() {
+		cancel()
+		<-done
+	})
+	h.stop = func() {
+		unregister()
+		cancel()
+		<-done
+	}
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.check(ctx)
+			}
+		}
+	}()
+
+	return h
+}
+
+// Healthy 最近的健康檢查是否正常，未啟用 EnableHealthCheck 時為 true
+func (c *Cacher) Healthy() bool {
+	return c.health == nil || c.health.Healthy()
+}
+
+// Healthy 連續失敗次數未達 FailureThreshold
+func (h *HealthChecker) Healthy() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.stats.Healthy
+}
+
+// Stats 取得統計
+func (h *HealthChecker) Stats() HealthStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.stats
+}
+
+// OnLost 設定斷線時的回呼，連續失敗達 FailureThreshold 時呼叫一次
+func (h *HealthChecker) OnLost(fn func(err error)) *HealthChecker {
+	h.mu.Lock()
+	h.onLost = append(h.onLost, fn)
+	h.mu.Unlock()
+
+	return h
+}
+
+// OnRecovered 設定恢復時的回呼，可用來重新載入腳本或重新訂閱
+func (h *HealthChecker) OnRecovered(fn func()) *HealthChecker {
+	h.mu.Lock()
+	h.onRecovered = append(h.onRecovered, fn)
+	h.mu.Unlock()
+
+	return h
+}
+
+// ServeHTTP readiness，不健康、延遲過高或不是 master(RequireMaster) 時返回 503，內容為 HealthStats 的 JSON
+func (h *HealthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stats := h.Stats()
+	ready := stats.Healthy &&
+		(h.opts.MaxLatency <= 0 || stats.Latency <= h.opts.MaxLatency) &&
+		(!h.opts.RequireMaster || stats.Role == "" || stats.Role == "master")
+	writeHealth(w, ready, stats)
+}
+
+// LivenessHandler liveness，背景檢查超過 3 個間隔加逾時沒有執行時返回 503，不受 Redis 狀態影響
+func (h *HealthChecker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := h.Stats()
+		writeHealth(w, time.Since(stats.LastCheck) <= 3*h.opts.Interval+h.opts.Timeout, stats)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, ok bool, stats HealthStats) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(stats)
+}
+
+// check PING 並更新統計，狀態改變時呼叫回呼；略過斷路器，反映 Redis 實際的狀態
+func (h *HealthChecker) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(withoutBreaker(ctx), h.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := h.cacher.pool.Ping(ctx).Err()
+	latency := time.Since(start)
+
+	var info string
+	if err == nil {
+		// INFO 不一定可用(ex. 權限不足)，失敗時不影響健康狀態
+		info, _ = h.cacher.pool.Info(ctx, "replication").Result()
+	}
+
+	h.mu.Lock()
+	s := &h.stats
+	was := s.Healthy
+	s.LastCheck = start
+	s.Checks++
+	if err != nil {
+		s.Failures++
+		s.ConsecutiveFailures++
+		s.LastError = err.Error()
+		if s.ConsecutiveFailures >= h.opts.FailureThreshold && s.Healthy {
+			s.Healthy = false
+			s.DownSince = start
+		}
+	} else {
+		s.ConsecutiveFailures = 0
+		s.LastError = ""
+		s.Healthy = true
+		s.DownSince = time.Time{}
+		s.Latency = latency
+		if s.AvgLatency == 0 {
+			s.AvgLatency = latency
+		} else {
+			s.AvgLatency = (s.AvgLatency*4 + latency) / 5
+		}
+		if info != "" {
+			parseReplicationInfo(info, s)
+		}
+	}
+	now := s.Healthy
+	onLost := h.onLost
+	onRecovered := h.onRecovered
+	h.mu.Unlock()
+
+	switch {
+	case was && !now:
+		if logger := h.cacher.getLogger(); logger != nil {
+			logger.Error("redis connection lost", "error", err)
+		}
+		for _, fn := range onLost {
+			fn(err)
+		}
+	case !was && now:
+		if logger := h.cacher.getLogger(); logger != nil {
+			logger.Info("redis connection recovered")
+		}
+		for _, fn := range onRecovered {
+			fn()
+		}
+	}
+}
+
+// parseReplicationInfo 解析 INFO replication
+func parseReplicationInfo(info string, s *HealthStats) {
+	for _, line := range strings.Split(info, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "role":
+			s.Role = kv[1]
+		case "connected_slaves":
+			s.ConnectedSlaves, _ = strconv.Atoi(kv[1])
+		case "master_link_status":
+			s.MasterLinkStatus = kv[1]
+		case "master_repl_offset":
+			s.ReplicationOffset, _ = strconv.ParseInt(kv[1], 10, 64)
+		}
+	}
+}
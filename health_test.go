@@ -0,0 +1,107 @@
+package redis
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacher_EnableHealthCheck(t *testing.T) {
+	c, s := newTestCacher(t)
+	h := c.EnableHealthCheck(HealthOptions{Interval: 10 * time.Millisecond, FailureThreshold: 2})
+	if !c.Healthy() {
+		t.Fatalf("Healthy() = false after start")
+	}
+	var lost, recovered int32
+	h.OnLost(func(err error) {
+		if err == nil {
+			t.Errorf("OnLost err = nil")
+		}
+		atomic.AddInt32(&lost, 1)
+	}).OnRecovered(func() {
+		atomic.AddInt32(&recovered, 1)
+	})
+
+	s.Close()
+	if !waitFor(time.Second, func() bool { return !c.Healthy() }) {
+		t.Fatalf("Healthy() = true after close")
+	}
+	stats := h.Stats()
+	if stats.ConsecutiveFailures < 2 || stats.LastError == "" || stats.DownSince.IsZero() {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != 503 {
+		t.Errorf("readiness code = %d, want 503", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.LivenessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/livez", nil))
+	if rec.Code != 200 {
+		t.Errorf("liveness code = %d, want 200", rec.Code)
+	}
+
+	if err := s.Restart(); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	// go-redis 連續撥號失敗後每秒才重試一次
+	if !waitFor(3*time.Second, c.Healthy) {
+		t.Fatalf("Healthy() = false after restart")
+	}
+	if atomic.LoadInt32(&lost) != 1 || atomic.LoadInt32(&recovered) != 1 {
+		t.Errorf("lost = %d, recovered = %d, want 1, 1", lost, recovered)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != 200 {
+		t.Errorf("readiness code = %d, want 200", rec.Code)
+	}
+	var body HealthStats
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || !body.Healthy || body.Latency <= 0 {
+		t.Errorf("readiness body = %+v, %v", body, err)
+	}
+}
+
+func TestHealthCheck_Replace(t *testing.T) {
+	c, _ := newTestCacher(t)
+	first := c.EnableHealthCheck(HealthOptions{Interval: 5 * time.Millisecond})
+	second := c.EnableHealthCheck(HealthOptions{Interval: 5 * time.Millisecond})
+
+	// 第二次呼叫已停止第一個檢查
+	checks := first.Stats().Checks
+	if !waitFor(time.Second, func() bool { return second.Stats().Checks > 3 }) {
+		t.Fatalf("second checker not running")
+	}
+	if got := first.Stats().Checks; got != checks {
+		t.Errorf("first checker Checks = %d, want %d after replaced", got, checks)
+	}
+}
+
+func TestHealthCheck_SkipBreaker(t *testing.T) {
+	c, _ := newTestCacher(t)
+	c.EnableCircuitBreaker(BreakerOptions{MinRequests: 2, CoolDown: time.Hour})
+	c.AddHook(&failHook{name: "get"})
+	c.Get("health-T1")
+	c.Get("health-T1")
+	if got := c.CircuitState(ClassRead); got != CircuitOpen {
+		t.Fatalf("CircuitState() = %v, want open", got)
+	}
+
+	// 斷路器開啟不影響 PING，Redis 實際上是正常的
+	h := c.EnableHealthCheck(HealthOptions{Interval: time.Hour})
+	if stats := h.Stats(); !stats.Healthy || stats.Failures != 0 {
+		t.Errorf("Stats() = %+v, want healthy", stats)
+	}
+}
+
+func TestParseReplicationInfo(t *testing.T) {
+	info := "# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\nmaster_link_status:down\r\nconnected_slaves:0\r\nmaster_repl_offset:1234\r\n"
+	var s HealthStats
+	parseReplicationInfo(info, &s)
+	if s.Role != "slave" || s.MasterLinkStatus != "down" || s.ReplicationOffset != 1234 || s.ConnectedSlaves != 0 {
+		t.Errorf("parseReplicationInfo() = %+v", s)
+	}
+}
@@ -14,6 +14,10 @@ type Cmd struct {
 	cmd *redis.Cmd
 	val interface{}
 	Err error
+	// Retries RetryPolicy 重試的次數
+	Retries int
+	// Fallback 結果來自降級時的記憶體儲存
+	Fallback bool
 }
 
 // NewCmd NewCmd
@@ -518,3 +522,38 @@ func appendArg(dst []interface{}, arg interface{}) []interface{} {
 		return append(dst, arg)
 	}
 }
+
+// formatArg 將參數轉成字串，規則與 go-redis 寫入指令參數時相同
+func formatArg(arg interface{}) string {
+	switch v := arg.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.FormatInt(int64(v), 10)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(v)
+	}
+}
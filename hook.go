@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Command hook 收到的指令
+type Command interface {
+	Name() string
+	Args() []interface{}
+	Err() error
+	// SetErr 設定指令的錯誤，可用於錯誤注入
+	SetErr(err error)
+}
+
+// Hook 指令的攔截介面，BeforeProcess 返回錯誤時不執行指令，
+// AfterProcess 依加入的相反順序呼叫，返回錯誤時取代指令的錯誤
+type Hook interface {
+	BeforeProcess(ctx context.Context, cmd Command) (context.Context, error)
+	AfterProcess(ctx context.Context, cmd Command) error
+	BeforeProcessPipeline(ctx context.Context, cmds []Command) (context.Context, error)
+	AfterProcessPipeline(ctx context.Context, cmds []Command) error
+}
+
+// hookList AddHook 加入的 hook，WithContext 產生的 Cacher 共用
+type hookList struct {
+	mu    sync.RWMutex
+	hooks []Hook
+}
+
+func (l *hookList) list() []Hook {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.hooks
+}
+
+// AddHook 加入 hook，Do、EvalSha、ScriptLoad、pipeline、redsync 的鎖及 Subscribe 的訂閱指令都會經過。
+// 需在開始執行指令前呼叫，go-redis 加入 hook 時沒有加鎖，與執行中的指令同時呼叫會產生 data race。
+func (c *Cacher) AddHook(h Hook) {
+	if c.hooks == nil {
+		c.hooks = &hookList{}
+	}
+	c.hooks.mu.Lock()
+	c.hooks.hooks = append(c.hooks.hooks[:len(c.hooks.hooks):len(c.hooks.hooks)], h)
+	c.hooks.mu.Unlock()
+
+	c.addRedisHook(hookAdapter{h})
+}
+
+// addRedisHook 將 go-redis hook 加到主庫、阻塞指令及從庫的連接池，呼叫者需在開始執行指令前使用
+func (c *Cacher) addRedisHook(h redis.Hook) {
+	c.pool.AddHook(h)
+	if c.blocking != nil {
+		c.blocking.AddHook(h)
+	}
+	if c.replicas != nil {
+		for _, node := range c.replicas.nodes {
+			node.client.AddHook(h)
+		}
+	}
+}
+
+// processHooks 對不經過 go-redis hook 的指令(ex. 發布訂閱的 SUBSCRIBE)依序呼叫 hook
+func (c *Cacher) processHooks(ctx context.Context, args []interface{}, fn func(ctx context.Context) error) error {
+	hooks := c.hooks.list()
+	if len(hooks) == 0 {
+		return fn(ctx)
+	}
+
+	cmd := redis.NewCmd(ctx, args...)
+	var i int
+	var err error
+	for ; i < len(hooks) && err == nil; i++ {
+		if ctx, err = hooks[i].BeforeProcess(ctx, cmd); err != nil {
+			cmd.SetErr(err)
+		}
+	}
+	if err == nil {
+		err = fn(ctx)
+		cmd.SetErr(err)
+	}
+	for i--; i >= 0; i-- {
+		if hookErr := hooks[i].AfterProcess(ctx, cmd); hookErr != nil {
+			err = hookErr
+			cmd.SetErr(err)
+		}
+	}
+
+	return err
+}
+
+// hookAdapter 將 Hook 轉成 go-redis 的 hook
+type hookAdapter struct {
+	hook Hook
+}
+
+func (a hookAdapter) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return a.hook.BeforeProcess(ctx, cmd)
+}
+
+func (a hookAdapter) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return a.hook.AfterProcess(ctx, cmd)
+}
+
+func (a hookAdapter) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return a.hook.BeforeProcessPipeline(ctx, toCommands(cmds))
+}
+
+func (a hookAdapter) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return a.hook.AfterProcessPipeline(ctx, toCommands(cmds))
+}
+
+func toCommands(cmds []redis.Cmder) []Command {
+	commands := make([]Command, len(cmds))
+	for i, cmd := range cmds {
+		commands[i] = cmd
+	}
+	return commands
+}
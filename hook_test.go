@@ -0,0 +1,136 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type recordHook struct {
+	mu       sync.Mutex
+	commands []string
+	failOn   string
+}
+
+func (h *recordHook) BeforeProcess(ctx context.Context, cmd Command) (context.Context, error) {
+	if h.failOn != "" && cmd.Name() == h.failOn {
+		return ctx, errors.New("injected")
+	}
+	return ctx, nil
+}
+
+func (h *recordHook) AfterProcess(ctx context.Context, cmd Command) error {
+	h.mu.Lock()
+	h.commands = append(h.commands, cmd.Name())
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *recordHook) BeforeProcessPipeline(ctx context.Context, cmds []Command) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *recordHook) AfterProcessPipeline(ctx context.Context, cmds []Command) error {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.Name()
+	}
+	h.mu.Lock()
+	h.commands = append(h.commands, "pipeline("+strings.Join(names, ",")+")")
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *recordHook) seen() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return strings.Join(h.commands, " ")
+}
+
+func TestCacher_AddHook(t *testing.T) {
+	c, _ := newTestCacher(t)
+	h := &recordHook{}
+	c.AddHook(h)
+
+	c.Do("SET", c.getKey("hook-T1"), "a")
+	sha, err := c.ScriptLoad("return 1")
+	if err != nil {
+		t.Fatalf("ScriptLoad() error = %v", err)
+	}
+	c.EvalSha(sha, []string{c.getKey("hook-T1")})
+	c.pool.Pipelined(context.Background(), func(p redis.Pipeliner) error {
+		p.Get(context.Background(), c.getKey("hook-T1"))
+		p.Incr(context.Background(), c.getKey("hook-T2"))
+		return nil
+	})
+	m := c.NewMutex("hook-lock")
+	if err := m.Lock(); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	m.UnLock()
+
+	sub, err := c.WithContext(context.Background(), "traceID").Subscribe(func(string, []byte) error { return nil }, "hook-ch")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	sub.PSubscribe("hook-*")
+	sub.Close()
+
+	got := h.seen()
+	for _, want := range []string{"set", "script", "evalsha", "pipeline(get,incr)", "evalsha", "subscribe", "psubscribe"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("hook missing %q, got %q", want, got)
+		}
+	}
+}
+
+func TestCacher_AddHookInject(t *testing.T) {
+	c, _ := newTestCacher(t)
+	c.AddHook(&recordHook{failOn: "get"})
+
+	if err := c.Get("hook-T3").Err; err == nil || err.Error() != "injected" {
+		t.Errorf("Get() error = %v, want injected", err)
+	}
+	if _, err := c.Subscribe(func(string, []byte) error { return nil }, "hook-ch"); err != nil {
+		t.Errorf("Subscribe() error = %v", err)
+	}
+
+	c.AddHook(&recordHook{failOn: "subscribe"})
+	if _, err := c.Subscribe(func(string, []byte) error { return nil }, "hook-ch"); err == nil || err.Error() != "injected" {
+		t.Errorf("Subscribe() error = %v, want injected", err)
+	}
+}
+
+func TestCacher_HooksBeforeUse(t *testing.T) {
+	c, _ := newTestCacher(t)
+
+	// 支援的順序：New 之後先加入所有 hook，再開始併發執行指令，-race 下不應有 data race
+	h := &recordHook{}
+	c.AddHook(h)
+	c.EnableTracing(TracingOptions{TracerProvider: &recordTracer{}})
+	c.EnableMetrics(NewPrometheusMetrics("", nil), time.Hour)
+	c.EnableSlowLog(SlowLogOptions{Threshold: time.Hour})
+	c.EnableCircuitBreaker(BreakerOptions{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				c.Set("hook-T4", "a", 0)
+				c.Get("hook-T4")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := strings.Count(h.seen(), "set"); n != 160 {
+		t.Errorf("hook saw %d SET, want 160", n)
+	}
+}
@@ -0,0 +1,213 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// LogLevel 日誌等級
+type LogLevel int
+
+const (
+	// LevelDebug 除錯，Debug 時的指令紀錄
+	LevelDebug LogLevel = iota
+	// LevelInfo 一般訊息
+	LevelInfo
+	// LevelWarn 警告
+	LevelWarn
+	// LevelError 錯誤
+	LevelError
+)
+
+// String 等級名稱
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "LEVEL(" + fmt.Sprint(int(l)) + ")"
+	}
+}
+
+// Logger 日誌介面，keysAndValues 為成對的欄位名稱及值，例如 "cmd", "GET", "duration", d
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// StdLogger 將 Logger 輸出到標準庫的 *log.Logger，格式為 "LEVEL msg key=value ..."
+type StdLogger struct {
+	logger *log.Logger
+	level  LogLevel
+}
+
+// NewStdLogger 產生 StdLogger，低於 level 的日誌不輸出，l 為 nil 時輸出到 stderr
+func NewStdLogger(l *log.Logger, level LogLevel) *StdLogger {
+	if l == nil {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &StdLogger{logger: l, level: level}
+}
+
+// Debug 實作 Logger
+func (l *StdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.output(LevelDebug, msg, keysAndValues)
+}
+
+// Info 實作 Logger
+func (l *StdLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.output(LevelInfo, msg, keysAndValues)
+}
+
+// Warn 實作 Logger
+func (l *StdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.output(LevelWarn, msg, keysAndValues)
+}
+
+// Error 實作 Logger
+func (l *StdLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.output(LevelError, msg, keysAndValues)
+}
+
+func (l *StdLogger) output(level LogLevel, msg string, keysAndValues []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		b.WriteByte(' ')
+		if i+1 == len(keysAndValues) {
+			fmt.Fprintf(&b, "!BADKEY=%v", keysAndValues[i])
+			break
+		}
+		fmt.Fprintf(&b, "%v=%s", keysAndValues[i], formatLogValue(keysAndValues[i+1]))
+	}
+	l.logger.Output(3, b.String())
+}
+
+// formatLogValue 含空白的值加上引號
+func formatLogValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// SetLogger 設定日誌介面，取代 Log
+func (c *Cacher) SetLogger(logger Logger) {
+	c.logger = logger
+}
+
+// getLogger 取得 start 或 SetLogger 設定的日誌介面，都沒有時返回 nil
+func (c *Cacher) getLogger() Logger {
+	return c.logger
+}
+
+// DebugLogOptions 指令除錯日誌設定
+type DebugLogOptions struct {
+	Statement StatementMode                   // 指令參數的記錄方式，預設 StatementRedacted
+	Redact    func(args []interface{}) string // 自訂指令的記錄內容，優先於 Statement
+	MaxLen    int                             // 指令紀錄的最大長度，預設256
+}
+
+// EnableDebugLog 以 Debug 等級記錄每個指令、耗時、錯誤及 WithContext 的追蹤值，需在開始執行指令前呼叫
+func (c *Cacher) EnableDebugLog(opts DebugLogOptions) {
+	logger := c.getLogger()
+	if logger == nil {
+		logger = NewStdLogger(nil, LevelDebug)
+		c.logger = logger
+	}
+	if opts.MaxLen <= 0 {
+		opts.MaxLen = 256
+	}
+	c.debugLog = true
+	c.addRedisHook(&loggingHook{logger: logger, opts: opts})
+}
+
+type logTraceKey struct{}
+
+// withLogTrace 開啟除錯日誌時將 WithContext 的追蹤值帶入 ctx
+func (c *Cacher) withLogTrace(ctx context.Context) context.Context {
+	if !c.debugLog || c.ctx.Context == nil {
+		return ctx
+	}
+	if traceID := c.ctx.Context.Value(c.ctx.Field); traceID != nil {
+		return context.WithValue(ctx, logTraceKey{}, traceID)
+	}
+	return ctx
+}
+
+type logStartKey struct{}
+
+// loggingHook 以 go-redis 的 hook 記錄指令
+type loggingHook struct {
+	logger Logger
+	opts   DebugLogOptions
+}
+
+func (h *loggingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, logStartKey{}, time.Now()), nil
+}
+
+func (h *loggingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.log(ctx, h.statement(cmd.Args()), cmd.Err())
+	return nil
+}
+
+func (h *loggingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, logStartKey{}, time.Now()), nil
+}
+
+func (h *loggingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	statements := make([]string, len(cmds))
+	var err error
+	for i, cmd := range cmds {
+		statements[i] = h.statement(cmd.Args())
+		if err == nil {
+			err = commandError(cmd.Err())
+		}
+	}
+	h.log(ctx, strings.Join(statements, "; "), err)
+
+	return nil
+}
+
+func (h *loggingHook) log(ctx context.Context, statement string, err error) {
+	fields := []interface{}{"cmd", statement}
+	if start, ok := ctx.Value(logStartKey{}).(time.Time); ok {
+		fields = append(fields, "duration", time.Since(start))
+	}
+	if traceID := ctx.Value(logTraceKey{}); traceID != nil {
+		fields = append(fields, "trace", traceID)
+	}
+	if err = commandError(err); err != nil {
+		fields = append(fields, "error", err)
+	}
+	h.logger.Debug("redis command", fields...)
+}
+
+func (h *loggingHook) statement(args []interface{}) string {
+	if h.opts.Redact != nil {
+		return h.opts.Redact(args)
+	}
+	return commandStatement(args, h.opts.Statement, h.opts.MaxLen)
+}
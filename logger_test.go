@@ -0,0 +1,118 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type logEntry struct {
+	level  LogLevel
+	msg    string
+	fields map[string]interface{}
+}
+
+// recordLogger 記錄日誌
+type recordLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordLogger) Debug(msg string, kv ...interface{}) { l.add(LevelDebug, msg, kv) }
+func (l *recordLogger) Info(msg string, kv ...interface{})  { l.add(LevelInfo, msg, kv) }
+func (l *recordLogger) Warn(msg string, kv ...interface{})  { l.add(LevelWarn, msg, kv) }
+func (l *recordLogger) Error(msg string, kv ...interface{}) { l.add(LevelError, msg, kv) }
+
+func (l *recordLogger) add(level LogLevel, msg string, kv []interface{}) {
+	fields := make(map[string]interface{})
+	for i := 0; i+1 < len(kv); i += 2 {
+		fields[kv[i].(string)] = kv[i+1]
+	}
+	l.mu.Lock()
+	l.entries = append(l.entries, logEntry{level, msg, fields})
+	l.mu.Unlock()
+}
+
+// has 是否記錄過 msg
+func (l *recordLogger) has(msg string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		if e.msg == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0), LevelInfo)
+
+	l.Debug("hidden")
+	l.Info("redis command", "cmd", "GET key", "n", 1)
+	l.Error("odd", "key")
+
+	want := "INFO redis command cmd=\"GET key\" n=1\nERROR odd !BADKEY=key\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCacher_EnableDebugLog(t *testing.T) {
+	c, _ := newTestCacher(t)
+	logger := &recordLogger{}
+	c.SetLogger(logger)
+	c.EnableDebugLog(DebugLogOptions{})
+
+	ctx := context.WithValue(context.Background(), "traceID", "trace-1")
+	cc := c.WithContext(ctx, "traceID")
+	cc.Set("log-T1", "secret", 0)
+	cc.Do("INCR", cc.getKey("log-T1"))
+
+	if len(logger.entries) != 2 {
+		t.Fatalf("entries = %+v, want 2", logger.entries)
+	}
+	set := logger.entries[0]
+	if set.level != LevelDebug || set.msg != "redis command" || set.fields["cmd"] != "SET RedisTest:log-T1 ?" ||
+		set.fields["trace"] != "trace-1" || set.fields["duration"] == nil || set.fields["error"] != nil {
+		t.Errorf("SET entry = %+v", set)
+	}
+	if incr := logger.entries[1]; incr.fields["error"] == nil {
+		t.Errorf("INCR entry = %+v, want error field", incr)
+	}
+}
+
+func TestCacher_DebugLogFull(t *testing.T) {
+	c, _ := newTestCacher(t)
+	var buf bytes.Buffer
+	c.SetLogger(NewStdLogger(log.New(&buf, "", 0), LevelDebug))
+	c.EnableDebugLog(DebugLogOptions{Statement: StatementFull, MaxLen: 24})
+
+	c.Set("log-T2", strings.Repeat("x", 50), 0)
+	if !strings.Contains(buf.String(), `cmd="SET RedisTest:log-T2 xxx..."`) {
+		t.Errorf("output = %q", buf.String())
+	}
+}
+
+func TestCacher_LogOnce(t *testing.T) {
+	s := newTestServer(t)
+	var buf bytes.Buffer
+	c := &Cacher{Log: log.New(&buf, "", 0)}
+	if err := c.StartAndGC(Config{Addr: s.Addr()}); err != nil {
+		t.Fatalf("StartAndGC() error = %v", err)
+	}
+	defer c.GracefulStop()
+
+	logger := c.getLogger()
+	if logger == nil || logger != c.getLogger() {
+		t.Fatalf("getLogger() = %v, want the same logger built from Log", logger)
+	}
+	logger.Info("hello")
+	if !strings.Contains(buf.String(), "hello") {
+		t.Errorf("Log output = %q", buf.String())
+	}
+}
@@ -0,0 +1,391 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/unit"
+)
+
+// Metrics 指標的輸出介面
+type Metrics interface {
+	// ObserveCommand 每個指令完成時呼叫，name 為小寫的指令名稱，pipeline 為 "pipeline"
+	ObserveCommand(name string, duration time.Duration, err error)
+	// ObservePoolStats 定期帶入連接池狀態
+	ObservePoolStats(stats PoolStats)
+}
+
+// PoolStats 連接池狀態
+type PoolStats struct {
+	Hits       uint32 // 從連接池取得閒置連接的次數
+	Misses     uint32 // 連接池沒有閒置連接的次數
+	Timeouts   uint32 // 等待連接逾時的次數
+	TotalConns uint32 // 連接總數
+	IdleConns  uint32 // 閒置連接數
+	StaleConns uint32 // 被移除的過期連接數
+}
+
+// PoolStats 取得連接池狀態
+func (c *Cacher) PoolStats() PoolStats {
+	return newPoolStats(c.pool.PoolStats())
+}
+
+func newPoolStats(s *redis.PoolStats) PoolStats {
+	return PoolStats{
+		Hits:       s.Hits,
+		Misses:     s.Misses,
+		Timeouts:   s.Timeouts,
+		TotalConns: s.TotalConns,
+		IdleConns:  s.IdleConns,
+		StaleConns: s.StaleConns,
+	}
+}
+
+// EnableMetrics 記錄每個指令的次數、錯誤及延遲，並每隔 interval(預設10秒) 輸出連接池狀態，GracefulStop 時停止。
+// 與 AddHook 相同，需在開始執行指令前呼叫。
+func (c *Cacher) EnableMetrics(m Metrics, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	c.addRedisHook(&metricsHook{metrics: m})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.onStop("metrics reporter", func() {
+		cancel()
+		<-done
+	})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			m.ObservePoolStats(c.PoolStats())
+			if bm, ok := m.(BlockingPoolMetrics); ok && c.blocking != nil {
+				bm.ObserveBlockingPoolStats(c.BlockingPoolStats())
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+type metricsStartKey struct{}
+
+// metricsHook 以 go-redis 的 hook 記錄指令的延遲
+type metricsHook struct {
+	metrics Metrics
+}
+
+func (h *metricsHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, metricsStartKey{}, time.Now()), nil
+}
+
+func (h *metricsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if start, ok := ctx.Value(metricsStartKey{}).(time.Time); ok {
+		h.metrics.ObserveCommand(cmd.Name(), time.Since(start), commandError(cmd.Err()))
+	}
+	return nil
+}
+
+func (h *metricsHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, metricsStartKey{}, time.Now()), nil
+}
+
+func (h *metricsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	start, ok := ctx.Value(metricsStartKey{}).(time.Time)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	for _, cmd := range cmds {
+		if err = commandError(cmd.Err()); err != nil {
+			break
+		}
+	}
+	h.metrics.ObserveCommand("pipeline", time.Since(start), err)
+
+	return nil
+}
+
+// commandError redis nil 不視為錯誤
+func commandError(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+// DefaultLatencyBuckets 預設的延遲分布區間(秒)
+var DefaultLatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// PrometheusMetrics 在記憶體中累計指標，以 Prometheus 文字格式輸出
+type PrometheusMetrics struct {
+	namespace string
+	buckets   []float64
+
+	mu       sync.Mutex
+	commands map[string]*commandMetrics
+	pool     PoolStats
+	blocking *PoolStats // 阻塞指令連接池，未啟用時為 nil
+}
+
+type commandMetrics struct {
+	count   uint64
+	errors  uint64
+	sum     float64
+	buckets []uint64 // 累計的分布，與 PrometheusMetrics.buckets 對應
+}
+
+// NewPrometheusMetrics 產生 PrometheusMetrics，namespace 預設 "redis"，buckets 預設 DefaultLatencyBuckets
+func NewPrometheusMetrics(namespace string, buckets []float64) *PrometheusMetrics {
+	if namespace == "" {
+		namespace = "redis"
+	}
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &PrometheusMetrics{
+		namespace: namespace,
+		buckets:   buckets,
+		commands:  make(map[string]*commandMetrics),
+	}
+}
+
+// ObserveCommand 實作 Metrics
+func (p *PrometheusMetrics) ObserveCommand(name string, duration time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	m, ok := p.commands[name]
+	if !ok {
+		m = &commandMetrics{buckets: make([]uint64, len(p.buckets))}
+		p.commands[name] = m
+	}
+	m.count++
+	if err != nil {
+		m.errors++
+	}
+	seconds := duration.Seconds()
+	m.sum += seconds
+	for i, le := range p.buckets {
+		if seconds <= le {
+			m.buckets[i]++
+		}
+	}
+}
+
+// ObservePoolStats 實作 Metrics
+func (p *PrometheusMetrics) ObservePoolStats(stats PoolStats) {
+	p.mu.Lock()
+	p.pool = stats
+	p.mu.Unlock()
+}
+
+// ObserveBlockingPoolStats 實作 BlockingPoolMetrics，以 pool="blocking" 標籤輸出
+func (p *PrometheusMetrics) ObserveBlockingPoolStats(stats PoolStats) {
+	p.mu.Lock()
+	p.blocking = &stats
+	p.mu.Unlock()
+}
+
+// WriteTo 以 Prometheus 文字格式輸出
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.commands))
+	for name := range p.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	ns := p.namespace
+
+	writeMetricHeader(bw, ns+"_commands_total", "counter", "Number of commands executed.")
+	for _, name := range names {
+		fmt.Fprintf(bw, "%s_commands_total{command=%q} %d\n", ns, name, p.commands[name].count)
+	}
+	writeMetricHeader(bw, ns+"_command_errors_total", "counter", "Number of commands that returned an error.")
+	for _, name := range names {
+		fmt.Fprintf(bw, "%s_command_errors_total{command=%q} %d\n", ns, name, p.commands[name].errors)
+	}
+	writeMetricHeader(bw, ns+"_command_duration_seconds", "histogram", "Command latency in seconds.")
+	for _, name := range names {
+		m := p.commands[name]
+		for i, le := range p.buckets {
+			fmt.Fprintf(bw, "%s_command_duration_seconds_bucket{command=%q,le=%q} %d\n",
+				ns, name, strconv.FormatFloat(le, 'f', -1, 64), m.buckets[i])
+		}
+		fmt.Fprintf(bw, "%s_command_duration_seconds_bucket{command=%q,le=\"+Inf\"} %d\n", ns, name, m.count)
+		fmt.Fprintf(bw, "%s_command_duration_seconds_sum{command=%q} %s\n", ns, name, strconv.FormatFloat(m.sum, 'f', -1, 64))
+		fmt.Fprintf(bw, "%s_command_duration_seconds_count{command=%q} %d\n", ns, name, m.count)
+	}
+
+	pool := []struct {
+		name, kind, help string
+		value            func(s *PoolStats) uint32
+	}{
+		{"pool_hits_total", "counter", "Number of times a free connection was found in the pool.", func(s *PoolStats) uint32 { return s.Hits }},
+		{"pool_misses_total", "counter", "Number of times a free connection was not found in the pool.", func(s *PoolStats) uint32 { return s.Misses }},
+		{"pool_timeouts_total", "counter", "Number of times a wait timeout occurred.", func(s *PoolStats) uint32 { return s.Timeouts }},
+		{"pool_total_conns", "gauge", "Number of total connections in the pool.", func(s *PoolStats) uint32 { return s.TotalConns }},
+		{"pool_idle_conns", "gauge", "Number of idle connections in the pool.", func(s *PoolStats) uint32 { return s.IdleConns }},
+		{"pool_stale_conns_total", "counter", "Number of stale connections removed from the pool.", func(s *PoolStats) uint32 { return s.StaleConns }},
+	}
+	for _, m := range pool {
+		writeMetricHeader(bw, ns+"_"+m.name, m.kind, m.help)
+		fmt.Fprintf(bw, "%s_%s %d\n", ns, m.name, m.value(&p.pool))
+		if p.blocking != nil {
+			fmt.Fprintf(bw, "%s_%s{pool=\"blocking\"} %d\n", ns, m.name, m.value(p.blocking))
+		}
+	}
+
+	err := bw.Flush()
+
+	return cw.n, err
+}
+
+// ServeHTTP 以 Prometheus 文字格式輸出，可直接掛在 /metrics
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// countWriter 記錄寫入的長度
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	return n, err
+}
+
+// OTelMetrics 將指標輸出到 OpenTelemetry 的 Meter
+type OTelMetrics struct {
+	commands metric.Int64Counter
+	errors   metric.Int64Counter
+	latency  metric.Float64ValueRecorder
+
+	hits       metric.Int64SumObserver
+	misses     metric.Int64SumObserver
+	timeouts   metric.Int64SumObserver
+	staleConns metric.Int64SumObserver
+	totalConns metric.Int64ValueObserver
+	idleConns  metric.Int64ValueObserver
+
+	mu       sync.Mutex
+	pool     PoolStats
+	blocking *PoolStats
+}
+
+// NewOTelMetrics 以 meter 建立指令及連接池的指標
+func NewOTelMetrics(meter metric.Meter) (m *OTelMetrics, err error) {
+	defer func() {
+		// metric.Must 建立失敗時會 panic
+		if r := recover(); r != nil {
+			m, err = nil, fmt.Errorf("metrics: %v", r)
+		}
+	}()
+
+	must := metric.Must(meter)
+	m = &OTelMetrics{
+		commands: must.NewInt64Counter("redis.commands",
+			metric.WithDescription("Number of commands executed")),
+		errors: must.NewInt64Counter("redis.command.errors",
+			metric.WithDescription("Number of commands that returned an error")),
+		latency: must.NewFloat64ValueRecorder("redis.command.duration",
+			metric.WithDescription("Command latency"), metric.WithUnit(unit.Milliseconds)),
+	}
+
+	batch := must.NewBatchObserver(m.observePool)
+	m.hits = batch.NewInt64SumObserver("redis.pool.hits",
+		metric.WithDescription("Number of times a free connection was found in the pool"))
+	m.misses = batch.NewInt64SumObserver("redis.pool.misses",
+		metric.WithDescription("Number of times a free connection was not found in the pool"))
+	m.timeouts = batch.NewInt64SumObserver("redis.pool.timeouts",
+		metric.WithDescription("Number of times a wait timeout occurred"))
+	m.staleConns = batch.NewInt64SumObserver("redis.pool.stale_conns",
+		metric.WithDescription("Number of stale connections removed from the pool"))
+	m.totalConns = batch.NewInt64ValueObserver("redis.pool.total_conns",
+		metric.WithDescription("Number of total connections in the pool"))
+	m.idleConns = batch.NewInt64ValueObserver("redis.pool.idle_conns",
+		metric.WithDescription("Number of idle connections in the pool"))
+
+	return m, nil
+}
+
+// ObserveCommand 實作 Metrics
+func (m *OTelMetrics) ObserveCommand(name string, duration time.Duration, err error) {
+	ctx := context.Background()
+	labels := []label.KeyValue{label.String("command", name)}
+
+	m.commands.Add(ctx, 1, labels...)
+	if err != nil {
+		m.errors.Add(ctx, 1, labels...)
+	}
+	m.latency.Record(ctx, float64(duration)/float64(time.Millisecond), labels...)
+}
+
+// ObservePoolStats 實作 Metrics，數值在 SDK 收集時輸出
+func (m *OTelMetrics) ObservePoolStats(stats PoolStats) {
+	m.mu.Lock()
+	m.pool = stats
+	m.mu.Unlock()
+}
+
+// ObserveBlockingPoolStats 實作 BlockingPoolMetrics，以 pool=blocking 標籤輸出
+func (m *OTelMetrics) ObserveBlockingPoolStats(stats PoolStats) {
+	m.mu.Lock()
+	m.blocking = &stats
+	m.mu.Unlock()
+}
+
+func (m *OTelMetrics) observePool(ctx context.Context, result metric.BatchObserverResult) {
+	m.mu.Lock()
+	s, blocking := m.pool, m.blocking
+	m.mu.Unlock()
+
+	m.observePoolStats(result, nil, s)
+	if blocking != nil {
+		m.observePoolStats(result, []label.KeyValue{label.String("pool", "blocking")}, *blocking)
+	}
+}
+
+func (m *OTelMetrics) observePoolStats(result metric.BatchObserverResult, labels []label.KeyValue, s PoolStats) {
+	result.Observe(labels,
+		m.hits.Observation(int64(s.Hits)),
+		m.misses.Observation(int64(s.Misses)),
+		m.timeouts.Observation(int64(s.Timeouts)),
+		m.staleConns.Observation(int64(s.StaleConns)),
+		m.totalConns.Observation(int64(s.TotalConns)),
+		m.idleConns.Observation(int64(s.IdleConns)),
+	)
+}
@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/number"
+)
+
+func TestCacher_EnableMetrics(t *testing.T) {
+	c, _ := newTestCacher(t)
+	m := NewPrometheusMetrics("", nil)
+	c.EnableMetrics(m, 5*time.Millisecond)
+
+	c.Set("metrics-T1", "a", 0)
+	c.Get("metrics-T1")
+	c.Get("metrics-T1-missing")
+	c.Do("INCR", c.getKey("metrics-T1"))
+	c.GracefulStop()
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE redis_commands_total counter",
+		`redis_commands_total{command="get"} 2`,
+		`redis_commands_total{command="set"} 1`,
+		`redis_command_errors_total{command="get"} 0`,
+		`redis_command_errors_total{command="incr"} 1`,
+		"# TYPE redis_command_duration_seconds histogram",
+		`redis_command_duration_seconds_bucket{command="get",le="+Inf"} 2`,
+		`redis_command_duration_seconds_count{command="get"} 2`,
+		"# TYPE redis_pool_total_conns gauge",
+		"redis_pool_hits_total ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestPrometheusMetrics_ServeHTTP(t *testing.T) {
+	m := NewPrometheusMetrics("app_redis", []float64{0.1, 0.01})
+	m.ObserveCommand("get", 5*time.Millisecond, nil)
+	m.ObservePoolStats(PoolStats{TotalConns: 3, IdleConns: 2})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	out := rec.Body.String()
+	for _, want := range []string{
+		`app_redis_command_duration_seconds_bucket{command="get",le="0.01"} 1`,
+		`app_redis_command_duration_seconds_bucket{command="get",le="0.1"} 1`,
+		`app_redis_command_duration_seconds_sum{command="get"} 0.005`,
+		"app_redis_pool_total_conns 3",
+		"app_redis_pool_idle_conns 2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+// recordMeter 記錄 OTel 指標的 MeterImpl
+type recordMeter struct {
+	mu      sync.Mutex
+	values  map[string]float64
+	runners []metric.AsyncBatchRunner
+}
+
+func (m *recordMeter) RecordBatch(ctx context.Context, labels []label.KeyValue, ms ...metric.Measurement) {
+}
+
+func (m *recordMeter) NewSyncInstrument(desc metric.Descriptor) (metric.SyncImpl, error) {
+	return &recordInstrument{meter: m, desc: desc}, nil
+}
+
+func (m *recordMeter) NewAsyncInstrument(desc metric.Descriptor, runner metric.AsyncRunner) (metric.AsyncImpl, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := runner.(metric.AsyncBatchRunner); ok {
+		found := false
+		for _, existing := range m.runners {
+			found = found || existing == r
+		}
+		if !found {
+			m.runners = append(m.runners, r)
+		}
+	}
+	return &recordInstrument{meter: m, desc: desc}, nil
+}
+
+func (m *recordMeter) record(desc metric.Descriptor, n number.Number, labels []label.KeyValue, add bool) {
+	key := desc.Name()
+	for _, l := range labels {
+		key += "," + string(l.Key) + "=" + l.Value.Emit()
+	}
+	v := n.CoerceToFloat64(desc.NumberKind())
+
+	m.mu.Lock()
+	if add {
+		m.values[key] += v
+	} else {
+		m.values[key] = v
+	}
+	m.mu.Unlock()
+}
+
+// collect 執行非同步的 observer
+func (m *recordMeter) collect() {
+	m.mu.Lock()
+	runners := m.runners
+	m.mu.Unlock()
+
+	for _, r := range runners {
+		r.Run(context.Background(), func(labels []label.KeyValue, obs ...metric.Observation) {
+			for _, o := range obs {
+				m.record(o.AsyncImpl().Descriptor(), o.Number(), labels, false)
+			}
+		})
+	}
+}
+
+type recordInstrument struct {
+	meter *recordMeter
+	desc  metric.Descriptor
+}
+
+func (i *recordInstrument) Implementation() interface{}                       { return i }
+func (i *recordInstrument) Descriptor() metric.Descriptor                     { return i.desc }
+func (i *recordInstrument) Bind(labels []label.KeyValue) metric.BoundSyncImpl { return nil }
+
+func (i *recordInstrument) RecordOne(ctx context.Context, n number.Number, labels []label.KeyValue) {
+	i.meter.record(i.desc, n, labels, true)
+}
+
+func TestOTelMetrics(t *testing.T) {
+	meter := &recordMeter{values: make(map[string]float64)}
+	m, err := NewOTelMetrics(metric.WrapMeterImpl(meter, "test"))
+	if err != nil {
+		t.Fatalf("NewOTelMetrics() error = %v", err)
+	}
+
+	m.ObserveCommand("get", 2*time.Millisecond, nil)
+	m.ObserveCommand("get", 4*time.Millisecond, errors.New("boom"))
+	m.ObservePoolStats(PoolStats{Hits: 7, TotalConns: 3})
+	meter.collect()
+
+	want := map[string]float64{
+		"redis.commands,command=get":         2,
+		"redis.command.errors,command=get":   1,
+		"redis.command.duration,command=get": 6,
+		"redis.pool.hits":                    7,
+		"redis.pool.total_conns":             3,
+		"redis.pool.idle_conns":              0,
+	}
+	for k, v := range want {
+		if got, ok := meter.values[k]; !ok || got != v {
+			t.Errorf("%s = %v, want %v", k, got, v)
+		}
+	}
+}
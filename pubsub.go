@@ -0,0 +1,457 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/**
+Redis 發布訂閱(pub/sub)是一種消息通信模式：發送者(pub)發送消息，訂閱者(sub)接收消息。
+Redis 客戶端可以訂閱任意數量的頻道。
+當有新消息通過 PUBLISH 命令發送給頻道 channel 時， 這個消息就會被發送給訂閱它的所有客戶端。
+**/
+
+// Publish 將信息發送到指定的頻道，返回接收到信息的訂閱者數量
+func (c *Cacher) Publish(channel, message string) (int64, error) {
+	return c.Do("PUBLISH", c.getChannel(channel), message).Int64()
+}
+
+// PubSubChannels 列出目前有訂閱者的頻道，pattern 為空時列出全部
+func (c *Cacher) PubSubChannels(pattern string) ([]string, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	channels, err := c.Do("PUBSUB", "CHANNELS", c.getChannel(pattern)).Strings()
+	if err != nil {
+		return nil, err
+	}
+	for i := range channels {
+		channels[i] = c.trimChannel(channels[i])
+	}
+
+	return channels, nil
+}
+
+// PubSubNumSub 取得頻道的訂閱者數量(不含模式訂閱)
+func (c *Cacher) PubSubNumSub(channels ...string) (map[string]int64, error) {
+	args := make([]interface{}, 1, 1+len(channels))
+	args[0] = "NUMSUB"
+	for _, channel := range channels {
+		args = append(args, c.getChannel(channel))
+	}
+	reply, err := c.Do("PUBSUB", args...).Int64Map()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]int64, len(reply))
+	for channel, n := range reply {
+		res[c.trimChannel(channel)] = n
+	}
+
+	return res, nil
+}
+
+// DeliveryMode 訊息派送方式
+type DeliveryMode int
+
+const (
+	// DeliveryConcurrent 每則訊息一個 goroutine，不保證順序(預設)
+	DeliveryConcurrent DeliveryMode = iota
+	// DeliveryOrdered 同一個頻道的訊息依序處理，頻道依名稱分配到固定數量的 worker，不同 worker 的頻道可同時處理
+	DeliveryOrdered
+	// DeliveryPool 以固定數量的 worker 處理，不保證順序
+	DeliveryPool
+)
+
+// OverflowPolicy 派送佇列滿時的處理方式
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 等待佇列有空位，期間暫停接收訊息(預設)
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest 丟棄佇列中最舊的訊息，
+	// DeliveryOrdered 以 worker 的佇列為單位，丟棄的可能是分配到同一個 worker 的其他頻道的訊息
+	OverflowDropOldest
+	// OverflowDropNewest 丟棄新收到的訊息
+	OverflowDropNewest
+)
+
+// getChannel 開啟 PrefixChannels 時將頻道名稱或模式加上前綴
+func (c *Cacher) getChannel(channel string) string {
+	if c.prefixChannels {
+		return c.getKey(channel)
+	}
+	return channel
+}
+
+func (c *Cacher) getChannels(channels []string) []string {
+	if !c.prefixChannels {
+		return channels
+	}
+	res := make([]string, len(channels))
+	for i, channel := range channels {
+		res[i] = c.getKey(channel)
+	}
+	return res
+}
+
+// trimChannel 開啟 PrefixChannels 時將頻道名稱去掉前綴
+func (c *Cacher) trimChannel(channel string) string {
+	if c.prefixChannels {
+		return c.trimKey(channel)
+	}
+	return channel
+}
+
+// SubscribeOptions 訂閱設定
+type SubscribeOptions struct {
+	Mode      DeliveryMode
+	Workers   int            // DeliveryOrdered 及 DeliveryPool 的 worker 數量，預設10
+	QueueSize int            // DeliveryOrdered 每個 worker 或 DeliveryPool 共用的佇列大小，預設100
+	Overflow  OverflowPolicy // DeliveryConcurrent 沒有佇列，不適用
+	// OnError 處理函式回傳錯誤或 panic 時的回呼，訂閱完成後立即生效，未設定時寫到 Logger
+	OnError func(channel string, err error)
+}
+
+// SubscriptionStats 訂閱統計
+type SubscriptionStats struct {
+	Received int64 // 收到的訊息數量
+	Handled  int64 // 處理完成的訊息數量，包含處理失敗的
+	Dropped  int64 // 因佇列滿或訂閱結束而丟棄的訊息數量
+}
+
+// Subscription 訂閱，可以動態增減頻道，Close、GracefulStop 或 WithContext 的 context 結束時停止接收
+type Subscription struct {
+	received int64
+	handled  int64
+	dropped  int64
+
+	cacher    *Cacher
+	pubSub    *redis.PubSub
+	onMessage func(pattern, channel string, data []byte) error
+	opts      SubscribeOptions
+	queues    []chan *redis.Message // DeliveryOrdered 每個 worker 的佇列，頻道依名稱的 hash 分配
+	pool      chan *redis.Message   // DeliveryPool 共用的佇列
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	onError func(channel string, err error)
+
+	wg         sync.WaitGroup
+	done       chan struct{}
+	closeErr   error
+	unregister func()
+}
+
+// Subscribe 訂閱給定的一個或多個頻道的信息，返回時已完成訂閱。
+// 支持redis服務停止或網絡異常等情況時，自動重新訂閱。
+// 每則訊息以一個 goroutine 處理，需要保證順序或限制併發時使用 SubscribeWithOptions。
+// onMessage 回傳的錯誤會交給 SubscribeOptions.OnError，未設定時寫到 Logger。
+func (c *Cacher) Subscribe(onMessage func(channel string, data []byte) error, channels ...string) (*Subscription, error) {
+	return c.SubscribeWithOptions(SubscribeOptions{}, onMessage, channels...)
+}
+
+// SubscribeWithOptions 依設定的派送方式訂閱頻道
+func (c *Cacher) SubscribeWithOptions(opts SubscribeOptions, onMessage func(channel string, data []byte) error, channels ...string) (*Subscription, error) {
+	if onMessage == nil {
+		panic("nil handler")
+	}
+
+	return c.subscribe(opts, func(pattern, channel string, data []byte) error {
+		return onMessage(channel, data)
+	}, channels, nil)
+}
+
+// PSubscribe 以模式訂閱頻道，例如 "orders.*"，處理函式會收到符合的模式及實際的頻道名稱
+func (c *Cacher) PSubscribe(onMessage func(pattern, channel string, data []byte) error, patterns ...string) (*Subscription, error) {
+	return c.PSubscribeWithOptions(SubscribeOptions{}, onMessage, patterns...)
+}
+
+// PSubscribeWithOptions 依設定的派送方式以模式訂閱頻道
+func (c *Cacher) PSubscribeWithOptions(opts SubscribeOptions, onMessage func(pattern, channel string, data []byte) error, patterns ...string) (*Subscription, error) {
+	if onMessage == nil {
+		panic("nil handler")
+	}
+
+	return c.subscribe(opts, onMessage, nil, patterns)
+}
+
+func (c *Cacher) subscribe(opts SubscribeOptions, onMessage func(pattern, channel string, data []byte) error, channels, patterns []string) (*Subscription, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 10
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+
+	parent := c.ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	var pubSub *redis.PubSub
+	command, names := "subscribe", c.getChannels(channels)
+	if len(patterns) > 0 {
+		command, names = "psubscribe", c.getChannels(patterns)
+	}
+	err := c.processHooks(ctx, subscribeArgs(command, names), func(ctx context.Context) error {
+		if command == "psubscribe" {
+			pubSub = c.pool.PSubscribe(ctx, names...)
+		} else {
+			pubSub = c.pool.Subscribe(ctx, names...)
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		// 等待訂閱確認，避免返回後才發布的訊息收不到
+		_, err := pubSub.Receive(ctx)
+		return err
+	})
+	if err != nil {
+		cancel()
+		if pubSub != nil {
+			pubSub.Close()
+		}
+		return nil, err
+	}
+
+	s := &Subscription{
+		cacher:    c,
+		pubSub:    pubSub,
+		onMessage: onMessage,
+		opts:      opts,
+		ctx:       ctx,
+		cancel:    cancel,
+		onError:   opts.OnError,
+		done:      make(chan struct{}),
+	}
+	s.unregister = c.onStop("subscription "+strings.Join(append(channels, patterns...), ","), func() {
+		s.Close()
+	})
+	switch opts.Mode {
+	case DeliveryOrdered:
+		s.queues = make([]chan *redis.Message, opts.Workers)
+		for i := range s.queues {
+			s.queues[i] = make(chan *redis.Message, opts.QueueSize)
+			s.wg.Add(1)
+			go s.work(s.queues[i])
+		}
+	case DeliveryPool:
+		s.pool = make(chan *redis.Message, opts.QueueSize)
+		for i := 0; i < opts.Workers; i++ {
+			s.wg.Add(1)
+			go s.work(s.pool)
+		}
+	}
+	go s.run(pubSub.Channel())
+
+	return s, nil
+}
+
+// Subscribe 追加訂閱頻道
+func (s *Subscription) Subscribe(channels ...string) error {
+	names := s.cacher.getChannels(channels)
+	return s.cacher.processHooks(s.ctx, subscribeArgs("subscribe", names), func(ctx context.Context) error {
+		return s.pubSub.Subscribe(ctx, names...)
+	})
+}
+
+// Unsubscribe 取消訂閱頻道，沒有指定時取消所有頻道
+func (s *Subscription) Unsubscribe(channels ...string) error {
+	names := s.cacher.getChannels(channels)
+	return s.cacher.processHooks(s.ctx, subscribeArgs("unsubscribe", names), func(ctx context.Context) error {
+		return s.pubSub.Unsubscribe(ctx, names...)
+	})
+}
+
+// PSubscribe 追加模式訂閱
+func (s *Subscription) PSubscribe(patterns ...string) error {
+	names := s.cacher.getChannels(patterns)
+	return s.cacher.processHooks(s.ctx, subscribeArgs("psubscribe", names), func(ctx context.Context) error {
+		return s.pubSub.PSubscribe(ctx, names...)
+	})
+}
+
+// PUnsubscribe 取消模式訂閱，沒有指定時取消所有模式
+func (s *Subscription) PUnsubscribe(patterns ...string) error {
+	names := s.cacher.getChannels(patterns)
+	return s.cacher.processHooks(s.ctx, subscribeArgs("punsubscribe", names), func(ctx context.Context) error {
+		return s.pubSub.PUnsubscribe(ctx, names...)
+	})
+}
+
+// OnError 替換處理函式回傳錯誤或 panic 時的回呼，設定前收到的訊息錯誤不會交給 fn，需要時改用 SubscribeOptions.OnError
+func (s *Subscription) OnError(fn func(channel string, err error)) *Subscription {
+	s.mu.Lock()
+	s.onError = fn
+	s.mu.Unlock()
+
+	return s
+}
+
+// Stats 取得訂閱統計
+func (s *Subscription) Stats() SubscriptionStats {
+	return SubscriptionStats{
+		Received: atomic.LoadInt64(&s.received),
+		Handled:  atomic.LoadInt64(&s.handled),
+		Dropped:  atomic.LoadInt64(&s.dropped),
+	}
+}
+
+// Done 訂閱結束且處理中的訊息都完成後關閉
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close 停止訂閱並等待佇列中及處理中的訊息完成，不可在處理函式中呼叫
+func (s *Subscription) Close() error {
+	s.cancel()
+	<-s.done
+
+	return s.closeErr
+}
+
+// subscribeArgs 訂閱指令的參數，給 hook 使用
+func subscribeArgs(command string, names []string) []interface{} {
+	args := make([]interface{}, 0, len(names)+1)
+	args = append(args, command)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	return args
+}
+
+func (s *Subscription) run(ch <-chan *redis.Message) {
+	defer func() {
+		s.closeErr = s.pubSub.Close()
+		// 佇列中已收到的訊息仍會處理完
+		for _, q := range s.queues {
+			close(q)
+		}
+		if s.pool != nil {
+			close(s.pool)
+		}
+		s.wg.Wait()
+		s.unregister()
+		close(s.done)
+	}()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			s.dispatch(msg)
+		}
+	}
+}
+
+// dispatch 依派送方式交給處理函式
+func (s *Subscription) dispatch(msg *redis.Message) {
+	atomic.AddInt64(&s.received, 1)
+
+	switch s.opts.Mode {
+	case DeliveryOrdered:
+		// 同一個頻道固定由同一個 worker 處理，goroutine 數量不隨頻道數增加
+		h := fnv.New32a()
+		h.Write([]byte(msg.Channel))
+		s.enqueue(s.queues[h.Sum32()%uint32(len(s.queues))], msg)
+	case DeliveryPool:
+		s.enqueue(s.pool, msg)
+	default:
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(msg)
+		}()
+	}
+}
+
+// enqueue 將訊息放入佇列，佇列滿時依 Overflow 處理
+func (s *Subscription) enqueue(q chan *redis.Message, msg *redis.Message) {
+	switch s.opts.Overflow {
+	case OverflowDropNewest:
+		select {
+		case q <- msg:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case q <- msg:
+				return
+			default:
+			}
+			select {
+			case <-q:
+				atomic.AddInt64(&s.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case q <- msg:
+		case <-s.ctx.Done():
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
+
+func (s *Subscription) work(q <-chan *redis.Message) {
+	defer s.wg.Done()
+
+	for msg := range q {
+		s.handle(msg)
+	}
+}
+
+func (s *Subscription) handle(msg *redis.Message) {
+	if err := s.call(msg); err != nil {
+		s.reportError(s.cacher.trimChannel(msg.Channel), err)
+	}
+	atomic.AddInt64(&s.handled, 1)
+}
+
+// call 執行處理函式，panic 視為錯誤
+func (s *Subscription) call(msg *redis.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("pubsub: panic: %v", r)
+		}
+	}()
+
+	pattern := msg.Pattern
+	if pattern != "" {
+		pattern = s.cacher.trimChannel(pattern)
+	}
+
+	return s.onMessage(pattern, s.cacher.trimChannel(msg.Channel), []byte(msg.Payload))
+}
+
+func (s *Subscription) reportError(channel string, err error) {
+	s.mu.Lock()
+	onError := s.onError
+	s.mu.Unlock()
+
+	if onError != nil {
+		onError(channel, err)
+		return
+	}
+	if logger := s.cacher.getLogger(); logger != nil {
+		logger.Error("subscribe handler failed", "channel", channel, "error", err)
+	}
+}
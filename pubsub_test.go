@@ -0,0 +1,395 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacher_SubscribeHandle(t *testing.T) {
+	c, s := newTestCacher(t)
+
+	var mu sync.Mutex
+	got := make(map[string]string)
+	sub, err := c.Subscribe(func(channel string, data []byte) error {
+		mu.Lock()
+		got[channel] = string(data)
+		mu.Unlock()
+		return nil
+	}, "sub-T1")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := sub.Subscribe("sub-T2"); err != nil {
+		t.Fatalf("Subscription.Subscribe() error = %v", err)
+	}
+	waitFor(time.Second, func() bool { return len(s.PubSubChannels("sub-*")) == 2 })
+
+	s.Publish("sub-T1", "a")
+	s.Publish("sub-T2", "b")
+	ok := waitFor(time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return got["sub-T1"] == "a" && got["sub-T2"] == "b"
+	})
+	if !ok {
+		t.Errorf("received = %v", got)
+	}
+
+	if err := sub.Unsubscribe("sub-T1"); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if !waitFor(time.Second, func() bool { return len(s.PubSubChannels("sub-*")) == 1 }) {
+		t.Errorf("channels = %v, want [sub-T2]", s.PubSubChannels("sub-*"))
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	select {
+	case <-sub.Done():
+	default:
+		t.Errorf("Done() not closed after Close()")
+	}
+}
+
+func TestCacher_SubscribeError(t *testing.T) {
+	c, s := newTestCacher(t)
+
+	errs := make(chan error, 2)
+	sub, err := c.Subscribe(func(channel string, data []byte) error {
+		if string(data) == "panic" {
+			panic("boom")
+		}
+		return errors.New("failed")
+	}, "sub-T3")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer sub.Close()
+	sub.OnError(func(channel string, err error) {
+		errs <- err
+	})
+
+	s.Publish("sub-T3", "error")
+	s.Publish("sub-T3", "panic")
+	for i := 0; i < 2; i++ {
+		select {
+		case <-errs:
+		case <-time.After(time.Second):
+			t.Fatalf("OnError() called %d times, want 2", i)
+		}
+	}
+}
+
+func TestCacher_SubscribeOptionsError(t *testing.T) {
+	c, s := newTestCacher(t)
+
+	errs := make(chan string, 1)
+	sub, err := c.SubscribeWithOptions(SubscribeOptions{
+		OnError: func(channel string, err error) {
+			errs <- channel
+		},
+	}, func(channel string, data []byte) error {
+		return errors.New("failed")
+	}, "sub-T8")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer sub.Close()
+
+	// 訂閱完成後立即發布，錯誤不會因為尚未設定回呼而遺失
+	s.Publish("sub-T8", "error")
+	select {
+	case channel := <-errs:
+		if channel != "sub-T8" {
+			t.Errorf("OnError() channel = %s, want sub-T8", channel)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("OnError() not called")
+	}
+}
+
+func TestCacher_SubscribeContext(t *testing.T) {
+	c, _ := newTestCacher(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sub, err := c.WithContext(ctx, "trace").Subscribe(func(channel string, data []byte) error {
+		return nil
+	}, "sub-T4")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	cancel()
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Done() not closed after context canceled")
+	}
+}
+
+func TestCacher_SubscribeGracefulStop(t *testing.T) {
+	c, _ := newTestCacher(t)
+
+	sub, err := c.Subscribe(func(channel string, data []byte) error {
+		return nil
+	}, "sub-T5")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	c.GracefulStop()
+	select {
+	case <-sub.Done():
+	default:
+		t.Errorf("Done() not closed after GracefulStop()")
+	}
+}
+
+func TestCacher_SubscribeOrdered(t *testing.T) {
+	c, s := newTestCacher(t)
+
+	var mu sync.Mutex
+	var got []string
+	sub, err := c.SubscribeWithOptions(SubscribeOptions{Mode: DeliveryOrdered}, func(channel string, data []byte) error {
+		mu.Lock()
+		got = append(got, string(data))
+		mu.Unlock()
+		return nil
+	}, "sub-T6")
+	if err != nil {
+		t.Fatalf("SubscribeWithOptions() error = %v", err)
+	}
+
+	var want []string
+	for i := 0; i < 50; i++ {
+		want = append(want, strconv.Itoa(i))
+		s.Publish("sub-T6", strconv.Itoa(i))
+	}
+	waitFor(time.Second, func() bool { return sub.Stats().Handled == 50 })
+	sub.Close()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("received = %v, want %v", got, want)
+	}
+}
+
+func TestCacher_SubscribeOrderedBounded(t *testing.T) {
+	c, s := newTestCacher(t)
+
+	var mu sync.Mutex
+	got := map[string][]string{}
+	channels := make([]string, 100)
+	for ch := range channels {
+		channels[ch] = "order:" + strconv.Itoa(ch)
+	}
+	sub, err := c.SubscribeWithOptions(SubscribeOptions{Mode: DeliveryOrdered, Workers: 4}, func(channel string, data []byte) error {
+		mu.Lock()
+		got[channel] = append(got[channel], string(data))
+		mu.Unlock()
+		return nil
+	}, channels...)
+	if err != nil {
+		t.Fatalf("SubscribeWithOptions() error = %v", err)
+	}
+	defer sub.Close()
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		for _, ch := range channels {
+			s.Publish(ch, strconv.Itoa(i))
+		}
+	}
+	if !waitFor(2*time.Second, func() bool { return sub.Stats().Handled == 500 }) {
+		t.Fatalf("Handled = %d, want 500", sub.Stats().Handled)
+	}
+	// 頻道數增加不會增加 goroutine
+	if n := runtime.NumGoroutine(); n > before+5 {
+		t.Errorf("goroutines = %d, before = %d", n, before)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for ch, msgs := range got {
+		if !reflect.DeepEqual(msgs, []string{"0", "1", "2", "3", "4"}) {
+			t.Errorf("%s received = %v", ch, msgs)
+		}
+	}
+}
+
+func TestCacher_SubscribeOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow OverflowPolicy
+		want     []string
+	}{
+		{name: "drop newest", overflow: OverflowDropNewest, want: []string{"1", "2"}},
+		{name: "drop oldest", overflow: OverflowDropOldest, want: []string{"1", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, s := newTestCacher(t)
+
+			started := make(chan struct{}, 5)
+			release := make(chan struct{})
+			var mu sync.Mutex
+			var got []string
+			sub, err := c.SubscribeWithOptions(SubscribeOptions{
+				Mode:      DeliveryPool,
+				Workers:   1,
+				QueueSize: 1,
+				Overflow:  tt.overflow,
+			}, func(channel string, data []byte) error {
+				mu.Lock()
+				got = append(got, string(data))
+				mu.Unlock()
+				started <- struct{}{}
+				<-release
+				return nil
+			}, "sub-T7")
+			if err != nil {
+				t.Fatalf("SubscribeWithOptions() error = %v", err)
+			}
+
+			// 第一則訊息佔住唯一的 worker，之後的訊息只能排進大小為1的佇列
+			s.Publish("sub-T7", "1")
+			<-started
+			for i := 2; i <= 5; i++ {
+				s.Publish("sub-T7", strconv.Itoa(i))
+			}
+			waitFor(time.Second, func() bool { return sub.Stats().Received == 5 })
+			close(release)
+			waitFor(time.Second, func() bool { return sub.Stats().Handled == 2 })
+			sub.Close()
+
+			stats := sub.Stats()
+			if stats.Received != 5 || stats.Handled != 2 || stats.Dropped != 3 {
+				t.Errorf("Stats() = %+v", stats)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("received = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacher_SubscribeOrderedDropOldest(t *testing.T) {
+	c, s := newTestCacher(t)
+
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var got []string
+	sub, err := c.SubscribeWithOptions(SubscribeOptions{
+		Mode:      DeliveryOrdered,
+		Workers:   1,
+		QueueSize: 1,
+		Overflow:  OverflowDropOldest,
+	}, func(channel string, data []byte) error {
+		mu.Lock()
+		got = append(got, channel+":"+string(data))
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		return nil
+	}, "sub-T9a", "sub-T9b")
+	if err != nil {
+		t.Fatalf("SubscribeWithOptions() error = %v", err)
+	}
+
+	// 兩個頻道共用唯一的 worker，佇列滿時丟棄的是另一個頻道較舊的訊息
+	s.Publish("sub-T9a", "1")
+	<-started
+	s.Publish("sub-T9a", "2")
+	s.Publish("sub-T9b", "1")
+	waitFor(time.Second, func() bool { return sub.Stats().Received == 3 })
+	close(release)
+	waitFor(time.Second, func() bool { return sub.Stats().Handled == 2 })
+	sub.Close()
+
+	if stats := sub.Stats(); stats.Dropped != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	want := []string{"sub-T9a:1", "sub-T9b:1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("received = %v, want %v", got, want)
+	}
+}
+
+func TestCacher_PSubscribe(t *testing.T) {
+	c, s := newTestCacher(t)
+	c.prefixChannels = true
+
+	type received struct{ pattern, channel, data string }
+	got := make(chan received, 2)
+	sub, err := c.PSubscribe(func(pattern, channel string, data []byte) error {
+		got <- received{pattern, channel, string(data)}
+		return nil
+	}, "psub.*")
+	if err != nil {
+		t.Fatalf("PSubscribe() error = %v", err)
+	}
+	defer sub.Close()
+
+	// 頻道名稱加上前綴後，沒有前綴的同名頻道收不到
+	s.Publish("psub.a", "other app")
+	if n, err := c.Publish("psub.a", "hello"); err != nil || n != 1 {
+		t.Fatalf("Publish() = %d, %v, want 1", n, err)
+	}
+	select {
+	case r := <-got:
+		if r != (received{"psub.*", "psub.a", "hello"}) {
+			t.Errorf("received = %+v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("message not received")
+	}
+
+	if err := sub.PUnsubscribe("psub.*"); err != nil {
+		t.Fatalf("PUnsubscribe() error = %v", err)
+	}
+	if !waitFor(time.Second, func() bool { return s.PubSubNumPat() == 0 }) {
+		t.Errorf("PubSubNumPat() = %d, want 0", s.PubSubNumPat())
+	}
+}
+
+func TestCacher_PubSubIntrospection(t *testing.T) {
+	c, _ := newTestCacher(t)
+	c.prefixChannels = true
+
+	sub, err := c.Subscribe(func(channel string, data []byte) error {
+		return nil
+	}, "intro.a", "intro.b")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer sub.Close()
+	waitFor(time.Second, func() bool {
+		channels, _ := c.PubSubChannels("intro.*")
+		return len(channels) == 2
+	})
+
+	channels, err := c.PubSubChannels("intro.*")
+	if err != nil {
+		t.Fatalf("PubSubChannels() error = %v", err)
+	}
+	sort.Strings(channels)
+	if !reflect.DeepEqual(channels, []string{"intro.a", "intro.b"}) {
+		t.Errorf("PubSubChannels() = %v", channels)
+	}
+
+	numSub, err := c.PubSubNumSub("intro.a", "intro.c")
+	if err != nil {
+		t.Fatalf("PubSubNumSub() error = %v", err)
+	}
+	if !reflect.DeepEqual(numSub, map[string]int64{"intro.a": 1, "intro.c": 0}) {
+		t.Errorf("PubSubNumSub() = %v", numSub)
+	}
+}
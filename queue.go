@@ -0,0 +1,378 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/**
+可靠佇列：工作取出時會被原子性的移到該消費者專屬的處理中列表(processing list)，
+處理完成後需 Ack，失敗則 Nack 放回佇列。消費者崩潰時，工作會留在處理中列表，
+超過可見時間(visibility timeout)後由 Reap 放回佇列，不會遺失。
+**/
+
+// ErrJobNotInFlight 工作已不在處理中(已被 Ack、Nack 或因超時被放回佇列)
+var ErrJobNotInFlight = errors.New("queue: job is not in flight")
+
+// queueTrackScript 紀錄工作的處理期限與所屬消費者
+// KEYS[1] inflight KEYS[2] owners ARGV[1] 期限(毫秒) ARGV[2] 工作 ARGV[3] 消費者
+var queueTrackScript = NewScript(2, `
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[2], ARGV[2], ARGV[3])
+return 1
+`)
+
+// queueAckScript 將工作從處理中移除，若 ARGV[2] 不為空則放到 KEYS[4] 的尾端(佇列前端)
+// KEYS[1] processing KEYS[2] inflight KEYS[3] owners KEYS[4] 目標列表 ARGV[1] 工作 ARGV[2] 放回的工作
+var queueAckScript = NewScript(4, `
+local removed = redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+if removed > 0 and ARGV[2] ~= '' then
+	redis.call('RPUSH', KEYS[4], ARGV[2])
+end
+return removed
+`)
+
+// queueReapScript 工作仍超過期限時，從所屬消費者的處理中列表放回佇列前端
+// KEYS[1] ready KEYS[2] inflight KEYS[3] owners KEYS[4] processing ARGV[1] 現在時間(毫秒) ARGV[2] 工作
+var queueReapScript = NewScript(4, `
+local deadline = redis.call('ZSCORE', KEYS[2], ARGV[2])
+if not deadline or tonumber(deadline) > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('LREM', KEYS[4], 1, ARGV[2])
+redis.call('ZREM', KEYS[2], ARGV[2])
+redis.call('HDEL', KEYS[3], ARGV[2])
+redis.call('RPUSH', KEYS[1], ARGV[2])
+return 1
+`)
+
+// queueRecoverScript 將消費者處理中列表內的工作全部放回佇列前端
+// KEYS[1] processing KEYS[2] inflight KEYS[3] owners KEYS[4] ready
+var queueRecoverScript = NewScript(4, `
+local count = 0
+local item = redis.call('LPOP', KEYS[1])
+while item do
+	redis.call('ZREM', KEYS[2], item)
+	redis.call('HDEL', KEYS[3], item)
+	redis.call('RPUSH', KEYS[4], item)
+	count = count + 1
+	item = redis.call('LPOP', KEYS[1])
+end
+return count
+`)
+
+// ReliableQueueOptions 可靠佇列設定
+type ReliableQueueOptions struct {
+	VisibilityTimeout time.Duration   // 工作取出後多久未 Ack 會被放回佇列，預設30秒
+	UseBLMove         bool            // 使用 BLMOVE 取出工作(redis 6.2 以上)，預設使用 BRPOPLPUSH
+	ReapBatch         int64           // 每次 Reap 最多處理的數量，預設100
+	OnReapError       func(err error) // RunReaper 的 Reap 失敗時呼叫，未設定時寫到 Logger
+}
+
+// ReliableQueue 可靠佇列
+type ReliableQueue struct {
+	cacher *Cacher
+	name   string
+	opts   ReliableQueueOptions
+}
+
+// Job 佇列中的工作
+type Job struct {
+	ID         string `json:"id"`
+	Body       string `json:"body"`                 // 經過 encode 的內容
+	Attempts   int    `json:"attempts"`             // 已失敗的次數
+	EnqueuedAt int64  `json:"enqueued_at"`          // 加入佇列的時間(unix 毫秒)
+	LastError  string `json:"last_error,omitempty"` // 最後一次失敗的錯誤訊息
+
+	raw      string
+	consumer string
+}
+
+// Scan 將工作內容 json decode 到 obj，與 Cmd.Scan 相同
+func (j *Job) Scan(obj interface{}) error {
+	return (&Cmd{val: j.Body}).Scan(obj)
+}
+
+// Bytes 取得工作內容
+func (j *Job) Bytes() []byte {
+	return []byte(j.Body)
+}
+
+// Consumer 取出該工作的消費者
+func (j *Job) Consumer() string {
+	return j.consumer
+}
+
+// NewReliableQueue 產生新的可靠佇列
+func (c *Cacher) NewReliableQueue(name string, opts ReliableQueueOptions) *ReliableQueue {
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = 30 * time.Second
+	}
+	if opts.ReapBatch <= 0 {
+		opts.ReapBatch = 100
+	}
+
+	return &ReliableQueue{
+		cacher: c,
+		name:   name,
+		opts:   opts,
+	}
+}
+
+// Push 將工作加入佇列，內容與 LPush 相同方式 encode
+func (q *ReliableQueue) Push(payloads ...interface{}) *Cmd {
+	members := make([]interface{}, 0, len(payloads))
+	for _, payload := range payloads {
+		raw, err := q.newJob(payload)
+		if err != nil {
+			return &Cmd{
+				Err: err,
+			}
+		}
+		members = append(members, raw)
+	}
+
+	return q.cacher.LPush(q.readyKey(), members...)
+}
+
+// Pop 取出一個工作並移到該消費者的處理中列表，佇列為空時最多阻塞 timeout 秒，超時返回 ErrNil。
+func (q *ReliableQueue) Pop(consumer string, timeout int) (*Job, error) {
+	processingKey := q.processingKey(consumer)
+	var cmd *Cmd
+	if q.opts.UseBLMove {
+		cmd = q.cacher.BLMove(q.readyKey(), processingKey, "RIGHT", "LEFT", timeout)
+	} else {
+		cmd = q.cacher.BRPopLPush(q.readyKey(), processingKey, timeout)
+	}
+	raw, err := cmd.String()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{}
+	if err := json.Unmarshal([]byte(raw), job); err != nil {
+		// 無法解析的內容移到死信列表，避免一直被放回佇列，原始內容保留在 Body
+		dead, _ := json.Marshal(&Job{Body: raw, LastError: err.Error()})
+		if deadErr := q.finish(&Job{raw: raw, consumer: consumer}, q.deadKey(), string(dead)); deadErr != nil {
+			return nil, fmt.Errorf("queue: invalid job: %s; move to dead letters: %s", err, deadErr)
+		}
+		return nil, err
+	}
+	job.raw = raw
+	job.consumer = consumer
+
+	deadline := time.Now().Add(q.opts.VisibilityTimeout).UnixNano() / int64(time.Millisecond)
+	_, err = queueTrackScript.DoScript(q.cacher,
+		q.cacher.getKey(q.inflightKey()),
+		q.cacher.getKey(q.ownersKey()),
+		deadline, raw, consumer,
+	)
+	if err != nil {
+		// BRPOPLPUSH 無法放在腳本內，記錄期限失敗時將工作放回佇列前端，否則工作會留在處理中列表而不會被 Reap
+		if putBackErr := q.finish(job, q.readyKey(), raw); putBackErr != nil {
+			return nil, fmt.Errorf("queue: track job: %s; put back: %s (use Recover(%q))", err, putBackErr, consumer)
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Ack 工作處理完成，從處理中列表移除
+func (q *ReliableQueue) Ack(job *Job) error {
+	return q.finish(job, q.readyKey(), "")
+}
+
+// Nack 工作處理失敗，放回佇列前端等待重新處理，Attempts 會加一
+func (q *ReliableQueue) Nack(job *Job) error {
+	retry := *job
+	retry.Attempts++
+	raw, err := json.Marshal(&retry)
+	if err != nil {
+		return err
+	}
+
+	return q.finish(job, q.readyKey(), string(raw))
+}
+
+// Fail 工作處理失敗且不再重試，移到死信列表(dead letter)並紀錄錯誤訊息
+func (q *ReliableQueue) Fail(job *Job, cause error) error {
+	dead := *job
+	dead.Attempts++
+	if cause != nil {
+		dead.LastError = cause.Error()
+	}
+	raw, err := json.Marshal(&dead)
+	if err != nil {
+		return err
+	}
+
+	return q.finish(job, q.deadKey(), string(raw))
+}
+
+// DeadLetters 取得死信列表中 start 到 stop 的工作，越早失敗的排越前面
+func (q *ReliableQueue) DeadLetters(start, stop int) ([]*Job, error) {
+	values, err := q.cacher.LRange(q.deadKey(), start, stop).Strings()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(values))
+	for _, raw := range values {
+		job := &Job{}
+		if err := json.Unmarshal([]byte(raw), job); err != nil {
+			return nil, err
+		}
+		job.raw = raw
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// Reap 將超過可見時間仍未 Ack 的工作放回佇列，返回放回的數量。
+// 處理中列表依消費者而不同，先取出到期的工作及所屬消費者，再逐一以腳本搬移，腳本只存取 KEYS 內的鍵
+func (q *ReliableQueue) Reap() (int64, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	items, err := q.cacher.Do("ZRANGEBYSCORE", q.cacher.getKey(q.inflightKey()), "-inf", now, "LIMIT", 0, q.opts.ReapBatch).Strings()
+	if err != nil || len(items) == 0 {
+		return 0, err
+	}
+	args := make([]interface{}, 1, 1+len(items))
+	args[0] = q.cacher.getKey(q.ownersKey())
+	for _, item := range items {
+		args = append(args, item)
+	}
+	owners, err := q.cacher.Do("HMGET", args...).Strings()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for i, item := range items {
+		n, err := Int64(queueReapScript.DoScript(q.cacher,
+			q.cacher.getKey(q.readyKey()),
+			q.cacher.getKey(q.inflightKey()),
+			q.cacher.getKey(q.ownersKey()),
+			q.cacher.getKey(q.processingKey(owners[i])),
+			now, item,
+		))
+		if err != nil {
+			return count, err
+		}
+		count += n
+	}
+
+	return count, nil
+}
+
+// RunReaper 每隔 interval 執行一次 Reap，直到 ctx 結束，失敗時交給 OnReapError
+func (q *ReliableQueue) RunReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := q.Reap(); err != nil {
+				q.reportReapError(err)
+			}
+		}
+	}
+}
+
+// reportReapError 交給 OnReapError，未設定時寫到 Logger
+func (q *ReliableQueue) reportReapError(err error) {
+	if q.opts.OnReapError != nil {
+		q.opts.OnReapError(err)
+		return
+	}
+	if logger := q.cacher.getLogger(); logger != nil {
+		logger.Error("queue reap failed", "queue", q.name, "error", err)
+	}
+}
+
+// Recover 將消費者處理中列表內的工作全部放回佇列，消費者重新啟動時呼叫，返回放回的數量
+func (q *ReliableQueue) Recover(consumer string) (int64, error) {
+	return Int64(queueRecoverScript.DoScript(q.cacher,
+		q.cacher.getKey(q.processingKey(consumer)),
+		q.cacher.getKey(q.inflightKey()),
+		q.cacher.getKey(q.ownersKey()),
+		q.cacher.getKey(q.readyKey()),
+	))
+}
+
+// Len 佇列中等待處理的工作數量
+func (q *ReliableQueue) Len() *Cmd {
+	return q.cacher.LLen(q.readyKey())
+}
+
+// finish 將工作從處理中移除，requeue 不為空時放到 target
+func (q *ReliableQueue) finish(job *Job, target, requeue string) error {
+	if job == nil || job.raw == "" {
+		return ErrJobNotInFlight
+	}
+	removed, err := Int64(queueAckScript.DoScript(q.cacher,
+		q.cacher.getKey(q.processingKey(job.consumer)),
+		q.cacher.getKey(q.inflightKey()),
+		q.cacher.getKey(q.ownersKey()),
+		q.cacher.getKey(target),
+		job.raw, requeue,
+	))
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrJobNotInFlight
+	}
+
+	return nil
+}
+
+// newJob 產生新的工作並序列化
+func (q *ReliableQueue) newJob(payload interface{}) (string, error) {
+	value, err := q.cacher.encode(payload)
+	if err != nil {
+		return "", err
+	}
+	id, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(&Job{
+		ID:         id,
+		Body:       formatArg(value),
+		EnqueuedAt: time.Now().UnixNano() / int64(time.Millisecond),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(raw), nil
+}
+
+func (q *ReliableQueue) readyKey() string {
+	return "queue:" + q.name
+}
+
+func (q *ReliableQueue) processingKey(consumer string) string {
+	return "queue:" + q.name + ":processing:" + consumer
+}
+
+func (q *ReliableQueue) inflightKey() string {
+	return "queue:" + q.name + ":inflight"
+}
+
+func (q *ReliableQueue) ownersKey() string {
+	return "queue:" + q.name + ":owners"
+}
+
+func (q *ReliableQueue) deadKey() string {
+	return "queue:" + q.name + ":dead"
+}
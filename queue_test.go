@@ -0,0 +1,218 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestReliableQueue_PopAck(t *testing.T) {
+	c, _ := newTestCacher(t)
+	q := c.NewReliableQueue("queue-T1", ReliableQueueOptions{})
+
+	type Order struct {
+		ID    int
+		Price float64
+	}
+	if cmd := q.Push(Order{ID: 1, Price: 9.5}); cmd.Err != nil {
+		t.Fatalf("Push() error = %v", cmd.Err)
+	}
+
+	job, err := q.Pop("worker-1", 1)
+	if err != nil {
+		t.Fatalf("Pop() error = %v", err)
+	}
+	var o Order
+	if err := job.Scan(&o); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if o.ID != 1 || o.Price != 9.5 {
+		t.Errorf("Scan() got %v", o)
+	}
+
+	if n, _ := c.LLen("queue:queue-T1:processing:worker-1").Int(); n != 1 {
+		t.Errorf("processing len = %d, want 1", n)
+	}
+	if err := q.Ack(job); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if n, _ := c.LLen("queue:queue-T1:processing:worker-1").Int(); n != 0 {
+		t.Errorf("processing len after ack = %d, want 0", n)
+	}
+	if err := q.Ack(job); err != ErrJobNotInFlight {
+		t.Errorf("Ack() twice error = %v, want %v", err, ErrJobNotInFlight)
+	}
+}
+
+func TestReliableQueue_Nack(t *testing.T) {
+	c, _ := newTestCacher(t)
+	q := c.NewReliableQueue("queue-T2", ReliableQueueOptions{})
+	q.Push("first", "second")
+
+	job, err := q.Pop("worker-1", 1)
+	if err != nil {
+		t.Fatalf("Pop() error = %v", err)
+	}
+	if job.Body != "first" {
+		t.Errorf("Pop() body = %s, want first", job.Body)
+	}
+	if err := q.Nack(job); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+
+	// 被 Nack 的工作放回佇列前端
+	retry, err := q.Pop("worker-1", 1)
+	if err != nil {
+		t.Fatalf("Pop() error = %v", err)
+	}
+	if retry.ID != job.ID || retry.Attempts != 1 {
+		t.Errorf("Pop() got %s attempts %d, want %s attempts 1", retry.ID, retry.Attempts, job.ID)
+	}
+}
+
+func TestReliableQueue_Reap(t *testing.T) {
+	c, _ := newTestCacher(t)
+	q := c.NewReliableQueue("queue-T3", ReliableQueueOptions{VisibilityTimeout: time.Millisecond})
+	q.Push("stuck")
+
+	job, err := q.Pop("worker-1", 1)
+	if err != nil {
+		t.Fatalf("Pop() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	n, err := q.Reap()
+	if err != nil {
+		t.Fatalf("Reap() error = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Reap() = %d, want 1", n)
+	}
+	if err := q.Ack(job); err != ErrJobNotInFlight {
+		t.Errorf("Ack() after reap error = %v, want %v", err, ErrJobNotInFlight)
+	}
+	if l, _ := q.Len().Int(); l != 1 {
+		t.Errorf("Len() = %d, want 1", l)
+	}
+}
+
+func TestReliableQueue_PopInvalid(t *testing.T) {
+	c, _ := newTestCacher(t)
+	q := c.NewReliableQueue("queue-T7", ReliableQueueOptions{})
+	c.LPush("queue:queue-T7", "not json")
+
+	if _, err := q.Pop("worker-1", 1); err == nil {
+		t.Fatalf("Pop() error = nil, want decode error")
+	}
+	if n, _ := c.LLen("queue:queue-T7:processing:worker-1").Int(); n != 0 {
+		t.Errorf("processing len = %d, want 0", n)
+	}
+	dead, err := q.DeadLetters(0, -1)
+	if err != nil {
+		t.Fatalf("DeadLetters() error = %v", err)
+	}
+	if len(dead) != 1 || dead[0].Body != "not json" || dead[0].LastError == "" {
+		t.Errorf("DeadLetters() = %+v, want invalid payload", dead)
+	}
+}
+
+func TestReliableQueue_Recover(t *testing.T) {
+	c, _ := newTestCacher(t)
+	q := c.NewReliableQueue("queue-T4", ReliableQueueOptions{})
+	q.Push("a", "b")
+	q.Pop("worker-1", 1)
+	q.Pop("worker-1", 1)
+
+	n, err := q.Recover("worker-1")
+	if err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Recover() = %d, want 2", n)
+	}
+	job, err := q.Pop("worker-2", 1)
+	if err != nil {
+		t.Fatalf("Pop() error = %v", err)
+	}
+	if job.Body != "a" {
+		t.Errorf("Pop() body = %s, want a", job.Body)
+	}
+}
+
+// cmdFailHook 讓接下來 n 次的 name 指令失敗
+type cmdFailHook struct {
+	name string
+	n    int32
+}
+
+func (h *cmdFailHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if cmd.Name() == h.name && atomic.AddInt32(&h.n, -1) >= 0 {
+		return ctx, errors.New("injected")
+	}
+	return ctx, nil
+}
+
+func (h *cmdFailHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *cmdFailHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *cmdFailHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestReliableQueue_PopTrackFailed(t *testing.T) {
+	c, _ := newTestCacher(t)
+	q := c.NewReliableQueue("queue-T5", ReliableQueueOptions{})
+	q.Push("a")
+	c.pool.AddHook(&cmdFailHook{name: "eval", n: 1})
+
+	if _, err := q.Pop("worker-1", 1); err == nil || err.Error() != "injected" {
+		t.Fatalf("Pop() error = %v, want injected", err)
+	}
+	if n, _ := c.LLen("queue:queue-T5:processing:worker-1").Int(); n != 0 {
+		t.Errorf("processing len = %d, want 0", n)
+	}
+	job, err := q.Pop("worker-1", 1)
+	if err != nil {
+		t.Fatalf("Pop() after put back error = %v", err)
+	}
+	if job.Body != "a" {
+		t.Errorf("Pop() body = %s, want a", job.Body)
+	}
+}
+
+func TestReliableQueue_RunReaperError(t *testing.T) {
+	c, _ := newTestCacher(t)
+	errs := make(chan error, 1)
+	q := c.NewReliableQueue("queue-T6", ReliableQueueOptions{
+		OnReapError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	c.pool.AddHook(&cmdFailHook{name: "zrangebyscore", n: math.MaxInt32})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.RunReaper(ctx, time.Millisecond)
+
+	select {
+	case err := <-errs:
+		if err.Error() != "injected" {
+			t.Errorf("OnReapError() error = %v, want injected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnReapError not called")
+	}
+}
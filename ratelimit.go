@@ -0,0 +1,324 @@
+package redis
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/**
+限流器：使用有序集合(ZSET)搭配lua腳本，確保多個實例共用同一份計數。
+**/
+
+// slidingWindowScript 滑動窗口日誌限流
+// KEYS[1] 限流鍵 ARGV[1] 現在時間(毫秒) ARGV[2] 窗口大小(毫秒) ARGV[3] 上限 ARGV[4] 本次請求的成員名稱
+// 回傳 {是否允許, 窗口內請求數, 最舊請求離開窗口的剩餘毫秒}
+var slidingWindowScript = NewScript(1, `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+// concurrencyScript 併發數限流
+// KEYS[1] 限流鍵 ARGV[1] 現在時間(毫秒) ARGV[2] 請求最長存活時間(毫秒) ARGV[3] 上限 ARGV[4] 本次請求的id
+// 回傳 {是否允許, 進行中的請求數}
+var concurrencyScript = NewScript(1, `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - ttl)
+local count = redis.call('ZCARD', key)
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, ttl)
+	return {1, count + 1}
+end
+return {0, count}
+`)
+
+// RateLimiter 限流器介面，Take 取得一次請求額度
+type RateLimiter interface {
+	Take(key string) (*RateLimitResult, error)
+}
+
+// RateLimitResult 限流結果
+type RateLimitResult struct {
+	Allowed    bool          // 是否允許本次請求
+	Limit      int64         // 上限
+	Remaining  int64         // 剩餘額度
+	RetryAfter time.Duration // 被拒絕時，建議多久後重試
+	ResetAfter time.Duration // 額度完全恢復的剩餘時間
+
+	release func() error
+}
+
+// Release 釋放本次取得的額度，只有併發數限流需要，其餘限流器呼叫不會有任何動作。
+func (r *RateLimitResult) Release() error {
+	if r == nil || r.release == nil {
+		return nil
+	}
+	release := r.release
+	r.release = nil
+
+	return release()
+}
+
+// SlidingWindowLimiter 滑動窗口日誌限流，window 時間內最多允許 limit 次請求
+type SlidingWindowLimiter struct {
+	cacher *Cacher
+	name   string
+	limit  int64
+	window time.Duration
+}
+
+// NewSlidingWindowLimiter 產生新的滑動窗口限流器，name 會作為鍵名的一部分以區分不同的限流器
+func (c *Cacher) NewSlidingWindowLimiter(name string, limit int64, window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		cacher: c,
+		name:   name,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Take 取得一次請求額度
+func (l *SlidingWindowLimiter) Take(key string) (*RateLimitResult, error) {
+	member, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	reply, err := Int64s(slidingWindowScript.DoScript(l.cacher,
+		l.cacher.getKey(rateLimitKey(l.name, key)),
+		now.UnixNano()/int64(time.Millisecond),
+		durationMillis(l.window),
+		l.limit,
+		strconv.FormatInt(now.UnixNano(), 10)+"-"+member,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) != 3 {
+		return nil, errors.New("ratelimit: unexpected script reply")
+	}
+
+	res := &RateLimitResult{
+		Allowed:    reply[0] == 1,
+		Limit:      l.limit,
+		Remaining:  l.limit - reply[1],
+		ResetAfter: time.Duration(reply[2]) * time.Millisecond,
+	}
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	if !res.Allowed {
+		res.RetryAfter = res.ResetAfter
+	}
+
+	return res, nil
+}
+
+// ConcurrencyLimiter 併發數限流，同時最多允許 limit 個進行中的請求。
+// ttl 為單一請求最長的持有時間，超過後即使沒有 Release 也會被回收(避免程式崩潰後永久佔用額度)。
+type ConcurrencyLimiter struct {
+	cacher *Cacher
+	name   string
+	limit  int64
+	ttl    time.Duration
+}
+
+// NewConcurrencyLimiter 產生新的併發數限流器
+func (c *Cacher) NewConcurrencyLimiter(name string, limit int64, ttl time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		cacher: c,
+		name:   name,
+		limit:  limit,
+		ttl:    ttl,
+	}
+}
+
+// Take 取得一個併發額度，請求結束後必須呼叫 RateLimitResult.Release 歸還
+func (l *ConcurrencyLimiter) Take(key string) (*RateLimitResult, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	limitKey := rateLimitKey(l.name, key)
+	reply, err := Int64s(concurrencyScript.DoScript(l.cacher,
+		l.cacher.getKey(limitKey),
+		time.Now().UnixNano()/int64(time.Millisecond),
+		durationMillis(l.ttl),
+		l.limit,
+		id,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) != 2 {
+		return nil, errors.New("ratelimit: unexpected script reply")
+	}
+
+	res := &RateLimitResult{
+		Allowed:   reply[0] == 1,
+		Limit:     l.limit,
+		Remaining: l.limit - reply[1],
+	}
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	if !res.Allowed {
+		// 無法得知其他請求何時結束，以1秒作為建議的重試時間
+		res.RetryAfter = time.Second
+		return res, nil
+	}
+	res.release = func() error {
+		return l.cacher.ZRem(limitKey, id).Err
+	}
+
+	return res, nil
+}
+
+// RateLimitKeyFunc 從請求中取出限流鍵，回傳空字串表示該請求不限流
+type RateLimitKeyFunc func(r *http.Request) string
+
+// KeyByIP 以來源IP作為限流鍵。
+// 若服務在反向代理之後，RemoteAddr 會是代理的位址，請改用 KeyByHeader("X-Real-IP") 之類的方式。
+func KeyByIP() RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+// KeyByHeader 以指定的header值作為限流鍵
+func KeyByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// KeyByContext 以request context 內的值作為限流鍵，例如驗證middleware放入的使用者id
+func KeyByContext(key interface{}) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		switch v := r.Context().Value(key).(type) {
+		case string:
+			return v
+		case int:
+			return strconv.Itoa(v)
+		case int64:
+			return strconv.FormatInt(v, 10)
+		default:
+			return ""
+		}
+	}
+}
+
+// RateLimitOptions http middleware 的設定
+type RateLimitOptions struct {
+	Limiter  RateLimiter
+	KeyFunc  RateLimitKeyFunc // 預設為 KeyByIP
+	FailOpen bool             // redis 發生錯誤時是否放行，false 時回傳 503
+	OnError  func(r *http.Request, err error)
+}
+
+// RateLimitMiddleware 產生限流用的 http middleware。
+// 被拒絕的請求會回傳 429 並帶上 Retry-After，所有經過限流的回應都會帶上 X-RateLimit-* header。
+func RateLimitMiddleware(opts RateLimitOptions) func(http.Handler) http.Handler {
+	if opts.Limiter == nil {
+		panic("nil limiter")
+	}
+	keyFunc := opts.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := opts.Limiter.Take(key)
+			if err != nil {
+				if opts.OnError != nil {
+					opts.OnError(r, err)
+				}
+				if opts.FailOpen {
+					next.ServeHTTP(w, r)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+
+			header := w.Header()
+			header.Set("X-RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+			header.Set("X-RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+			if res.ResetAfter > 0 {
+				header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(res.ResetAfter).Unix(), 10))
+			}
+			if !res.Allowed {
+				retryAfter := int64(math.Ceil(res.RetryAfter.Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				header.Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			defer res.Release()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey 限流用的鍵名
+func rateLimitKey(name, key string) string {
+	return "ratelimit:" + name + ":" + key
+}
+
+// durationMillis 將時間轉為毫秒，最少為1毫秒
+func durationMillis(d time.Duration) int64 {
+	ms := int64(d / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
+
+// randomToken 產生隨機字串
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
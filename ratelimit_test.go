@@ -0,0 +1,153 @@
+package redis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowLimiter_Take(t *testing.T) {
+	c, _ := newTestCacher(t)
+	limiter := c.NewSlidingWindowLimiter("sliding-T1", 2, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		res, err := limiter.Take("user-1")
+		if err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if !res.Allowed {
+			t.Errorf("Take() #%d allowed = false, want true", i)
+		}
+		if res.Remaining != int64(1-i) {
+			t.Errorf("Take() #%d remaining = %d, want %d", i, res.Remaining, 1-i)
+		}
+	}
+
+	res, err := limiter.Take("user-1")
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if res.Allowed {
+		t.Errorf("Take() allowed = true, want false")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Errorf("Take() retryAfter = %v", res.RetryAfter)
+	}
+
+	// 不同的key不互相影響
+	res, err = limiter.Take("user-2")
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if !res.Allowed {
+		t.Errorf("Take() other key allowed = false, want true")
+	}
+}
+
+func TestConcurrencyLimiter_Take(t *testing.T) {
+	c, _ := newTestCacher(t)
+	limiter := c.NewConcurrencyLimiter("concurrency-T1", 1, time.Minute)
+
+	first, err := limiter.Take("user-1")
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if !first.Allowed {
+		t.Fatalf("Take() allowed = false, want true")
+	}
+
+	second, err := limiter.Take("user-1")
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if second.Allowed {
+		t.Errorf("Take() allowed = true, want false")
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	third, err := limiter.Take("user-1")
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if !third.Allowed {
+		t.Errorf("Take() after release allowed = false, want true")
+	}
+	third.Release()
+}
+
+type errLimiter struct{}
+
+func (errLimiter) Take(key string) (*RateLimitResult, error) {
+	return nil, ErrNil
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	c, _ := newTestCacher(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		opts     RateLimitOptions
+		requests int
+		want     int
+	}{
+		{
+			name: "testAllowed",
+			opts: RateLimitOptions{
+				Limiter: c.NewSlidingWindowLimiter("middleware-T1", 2, time.Minute),
+				KeyFunc: KeyByHeader("X-User"),
+			},
+			requests: 2,
+			want:     http.StatusOK,
+		},
+		{
+			name: "testTooManyRequests",
+			opts: RateLimitOptions{
+				Limiter: c.NewSlidingWindowLimiter("middleware-T2", 2, time.Minute),
+				KeyFunc: KeyByHeader("X-User"),
+			},
+			requests: 3,
+			want:     http.StatusTooManyRequests,
+		},
+		{
+			name: "testFailOpen",
+			opts: RateLimitOptions{
+				Limiter:  errLimiter{},
+				FailOpen: true,
+			},
+			requests: 1,
+			want:     http.StatusOK,
+		},
+		{
+			name: "testFailClosed",
+			opts: RateLimitOptions{
+				Limiter: errLimiter{},
+			},
+			requests: 1,
+			want:     http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RateLimitMiddleware(tt.opts)(ok)
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-User", "user-1")
+				rec = httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+			}
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+				t.Errorf("missing Retry-After header")
+			}
+		})
+	}
+}
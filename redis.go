@@ -9,11 +9,15 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/go-redsync/redsync/v4"
 	redsynclib "github.com/go-redsync/redsync/v4/redis/goredis/v8"
+	"go.opentelemetry.io/otel/label"
 )
 
 // Cacher 先構建一個Cacher實例，然後將配置參數傳入該實例的StartAndGC方法來初始化實例和程序進程退出後的清理工作。
@@ -23,6 +27,24 @@ type Cacher struct {
 	prefix    string
 	Log       *log.Logger
 	ctx       ContextTraceInfo
+	stopper   *stopper
+
+	prefixChannels bool
+	tracing        *tracingHook
+	logger         Logger
+	debugLog       bool
+	hooks          *hookList
+	breaker        *breakerHook
+	retry          *RetryPolicy
+	forceRetry     bool
+	fallback       *fallbackStore
+	health         *HealthChecker
+	lifecycle      *lifecycle
+	timeout        time.Duration
+	timeouts       *TimeoutOptions
+	replicas       *replicaSet
+	blocking       *redis.Client
+	usePrimary     bool
 }
 
 // ContextTraceInfo context 用的struct
@@ -33,24 +55,33 @@ type ContextTraceInfo struct {
 
 // Options redis配置參數
 type Options struct {
-	Addr         string // redis服務的地址，默認為 127.0.0.1:6379
-	Password     string // redis鑒權密碼
-	Db           int    // 數據庫
-	Debug        bool
-	MaxRetries   int    // 放棄前會重試幾次
-	PoolSize     int    // 池子大小
-	MaxActive    int    // 最大活動連接數，值為0時表示不限制 (預計拿掉)
-	MaxIdle      int    // 最大空閑連接數 (預計拿掉)
-	MinIdle      int    // 最小空閒連接數
-	MaxConnAge   int    // redis連接的最大存活時間 默認不會關閉過期連結
-	DialTimeout  int    // redis連接的超時時間，超過該時間則關閉連接。單位為秒。默認值是3秒。
-	IdleTimeout  int    // 空閑連接的超時時間，超過該時間則關閉連接。單位為秒。默認值是5分鐘。值為0時表示不關閉空閑連接。此值應該總是大於redis服務的超時時間。
-	PoolTimeout  int    // 連接池的超時時間，超過該時間則關閉連接。單位為秒。默認值是4秒。值為0時表示不關閉空閑連接。此值應該總是大於redis服務的超時時間。
-	ReadTimeout  int    // socket read timeout 超過時間會導致指令失敗(ex. BLPOP超過秒數) 預設三秒
-	WriteTimeout int    // socket read timeout 超過時間會導致指令失敗 預設為ReadTimeout
-	Prefix       string // 鍵名前綴
-	Wait         bool   // 取不到連線池時是否等待
-	Log          *log.Logger
+	Addr           string // redis服務的地址，默認為 127.0.0.1:6379
+	Password       string // redis鑒權密碼
+	Db             int    // 數據庫
+	Debug          bool
+	MaxRetries     int    // 唯讀及冪等指令放棄前會重試幾次，預設3，-1 表示不重試；INCRBY、LPUSH 等非冪等指令不重試
+	PoolSize       int    // 池子大小
+	MaxActive      int    // 最大活動連接數，值為0時表示不限制 (預計拿掉)
+	MaxIdle        int    // 最大空閑連接數 (預計拿掉)
+	MinIdle        int    // 最小空閒連接數
+	MaxConnAge     int    // redis連接的最大存活時間 默認不會關閉過期連結
+	DialTimeout    int    // redis連接的超時時間，超過該時間則關閉連接。單位為秒。默認值是3秒。
+	IdleTimeout    int    // 空閑連接的超時時間，超過該時間則關閉連接。單位為秒。默認值是5分鐘。值為0時表示不關閉空閑連接。此值應該總是大於redis服務的超時時間。
+	PoolTimeout    int    // 連接池的超時時間，超過該時間則關閉連接。單位為秒。默認值是4秒。值為0時表示不關閉空閑連接。此值應該總是大於redis服務的超時時間。
+	ReadTimeout    int    // socket read timeout 超過時間會導致指令失敗(ex. BLPOP超過秒數) 預設三秒
+	WriteTimeout   int    // socket read timeout 超過時間會導致指令失敗 預設為ReadTimeout
+	Prefix         string // 鍵名前綴
+	PrefixChannels bool   // 發布訂閱的頻道名稱也加上 Prefix，避免共用 redis 的服務互相干擾
+	Wait           bool   // 取不到連線池時是否等待
+	Log            *log.Logger
+	Logger         Logger              // 日誌介面，未設定時使用 Log
+	LogStatement   StatementMode       // Debug 時指令參數的記錄方式，預設只保留指令及鍵名
+	LogMaxLen      int                 // Debug 時指令紀錄的最大長度，預設256
+	Retry          *RetryPolicy        // 依指令決定的重試策略，設定時取代 MaxRetries
+	Timeouts       TimeoutOptions      // 以 time.Duration 設定的逾時及每個指令的逾時
+	Strict         bool                // 設定了已無作用的欄位(MaxActive、MaxIdle、Wait)時返回錯誤而非警告
+	Replicas       ReplicaOptions      // 從庫地址，設定時唯讀指令改由從庫執行
+	Blocking       BlockingPoolOptions // 阻塞指令專用的連接池，PoolSize 大於0時啟用
 }
 
 // New 根據配置參數創建redis工具實例
@@ -73,100 +104,145 @@ func New(options Options) (r *Cacher, err error) {
 	return r, err
 }
 
-// StartAndGC 使用 Options 初始化redis，並在程序進程退出時關閉連接池。
+// StartAndGC 使用 Options 或 Config 初始化redis，並在程序進程退出時關閉連接池。
 func (c *Cacher) StartAndGC(options interface{}) error {
 	switch opts := options.(type) {
 	case Options:
-		if opts.Addr == "" {
-			return errors.New("miss Addr")
+		if err := opts.Validate(); err != nil {
+			return err
 		}
-		redisOption := &redis.Options{
-			Addr: opts.Addr,
-			DB:   opts.Db,
-		}
-		if opts.Password != "" {
-			redisOption.Password = opts.Password
-		}
-		if opts.MaxRetries != 0 {
-			redisOption.MaxRetries = opts.MaxRetries
-		}
-		if opts.MaxConnAge != 0 {
-			redisOption.MaxConnAge = (time.Duration(opts.MaxConnAge) * time.Second)
-		}
-		if opts.DialTimeout != 0 {
-			redisOption.DialTimeout = (time.Duration(opts.DialTimeout) * time.Second)
-		}
-		if opts.IdleTimeout != 0 {
-			redisOption.IdleTimeout = (time.Duration(opts.IdleTimeout) * time.Second)
+		c.Log = opts.Log
+		cfg := opts.Config()
+		if ignored := opts.ignoredFields(); len(ignored) > 0 {
+			logger := cfg.Logger
+			if logger == nil {
+				logger = NewStdLogger(nil, LevelWarn)
+			}
+			logger.Warn("redis options ignored", "fields", strings.Join(ignored, ","))
 		}
-		if opts.PoolSize != 0 {
-			redisOption.PoolSize = opts.PoolSize
+		return c.start(cfg)
+	case Config:
+		if err := opts.Validate(); err != nil {
+			return err
 		}
-		if opts.MinIdle != 0 {
-			redisOption.MinIdleConns = opts.MinIdle
+		return c.start(opts)
+	default:
+		return errors.New("unsupported options")
+	}
+}
+
+// start 依 Config 建立連接池及各項設定
+func (c *Cacher) start(cfg Config) error {
+	redisOption, err := cfg.redisOptions()
+	if err != nil {
+		return err
+	}
+	// 預設也依指令決定是否重試，非冪等指令不重試；MaxRetries 為 -1 時不重試
+	switch {
+	case cfg.Retry != nil:
+		policy := cfg.Retry.withDefaults()
+		c.retry = &policy
+	case cfg.MaxRetries >= 0:
+		policy := RetryPolicy{MaxRetries: cfg.MaxRetries}.withDefaults()
+		c.retry = &policy
+	}
+	if cfg.CommandTimeout > 0 || len(cfg.CommandTimeouts) > 0 {
+		timeouts := TimeoutOptions{
+			Default:  cfg.CommandTimeout,
+			Commands: make(map[string]time.Duration, len(cfg.CommandTimeouts)),
 		}
-		if opts.ReadTimeout != 0 {
-			redisOption.ReadTimeout = (time.Duration(opts.ReadTimeout) * time.Second)
+		for name, d := range cfg.CommandTimeouts {
+			timeouts.Commands[strings.ToUpper(name)] = d
 		}
-		if opts.WriteTimeout != 0 {
-			redisOption.WriteTimeout = (time.Duration(opts.WriteTimeout) * time.Second)
+		c.timeouts = &timeouts
+	}
+	client := redis.NewClient(redisOption)
+	// 最先執行，Shutdown 後的指令不會進到其他 hook
+	c.lifecycle = newLifecycle()
+	client.AddHook(lifecycleHook{c.lifecycle})
+
+	syncPool := redsynclib.NewPool(client)
+	rs := redsync.New(syncPool)
+	c.syncRedis = rs
+	c.hooks = &hookList{}
+
+	// pool := &redis.Pool{
+	// 	MaxActive:   opts.MaxActive,
+	// 	MaxIdle:     opts.MaxIdle,
+	// 	IdleTimeout: time.Duration(opts.IdleTimeout) * time.Second,
+
+	// 	Dial: func() (redis.Conn, error) {
+	// 		conn, err := redis.Dial("tcp", opts.Addr)
+	// 		if err != nil {
+	// 			return nil, err
+	// 		}
+	// 		if opts.Password != "" {
+	// 			if _, err := conn.Do("AUTH", opts.Password); err != nil {
+	// 				conn.Close()
+	// 				return nil, err
+	// 			}
+	// 		}
+	// 		if _, err := conn.Do("SELECT", opts.Db); err != nil {
+	// 			conn.Close()
+	// 			return nil, err
+	// 		}
+
+	// 		if opts.Debug {
+	// 			if opts.Log == nil {
+	// 				conn.Close()
+	// 				return nil, fmt.Errorf(`empty debug log object`)
+	// 			}
+	// 			conn = redis.NewLoggingConn(conn, opts.Log, "redis")
+	// 		}
+
+	// 		return conn, err
+	// 	},
+
+	// 	TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+	// 		if time.Since(t) < (time.Second * 30) {
+	// 			return nil
+	// 		}
+	// 		_, err := conn.Do("PING")
+	// 		return err
+	// 	},
+	// 	Wait: opts.Wait,
+	// }
+	c.prefix = cfg.Prefix
+	c.prefixChannels = cfg.PrefixChannels
+	c.pool = client
+	c.stopper = &stopper{}
+	if cfg.Blocking.PoolSize > 0 {
+		c.blocking = newBlockingClient(cfg.Blocking, redisOption, c.lifecycle)
+	}
+	if len(cfg.Replicas.Addrs) > 0 {
+		replicas, err := newReplicaSet(cfg, redisOption, c.lifecycle)
+		if err != nil {
+			c.closePools()
+			return err
 		}
-		client := redis.NewClient(redisOption)
-
-		syncPool := redsynclib.NewPool(client)
-		rs := redsync.New(syncPool)
-		c.syncRedis = rs
-
-		// pool := &redis.Pool{
-		// 	MaxActive:   opts.MaxActive,
-		// 	MaxIdle:     opts.MaxIdle,
-		// 	IdleTimeout: time.Duration(opts.IdleTimeout) * time.Second,
-
-		// 	Dial: func() (redis.Conn, error) {
-		// 		conn, err := redis.Dial("tcp", opts.Addr)
-		// 		if err != nil {
-		// 			return nil, err
-		// 		}
-		// 		if opts.Password != "" {
-		// 			if _, err := conn.Do("AUTH", opts.Password); err != nil {
-		// 				conn.Close()
-		// 				return nil, err
-		// 			}
-		// 		}
-		// 		if _, err := conn.Do("SELECT", opts.Db); err != nil {
-		// 			conn.Close()
-		// 			return nil, err
-		// 		}
-
-		// 		if opts.Debug {
-		// 			if opts.Log == nil {
-		// 				conn.Close()
-		// 				return nil, fmt.Errorf(`empty debug log object`)
-		// 			}
-		// 			conn = redis.NewLoggingConn(conn, opts.Log, "redis")
-		// 		}
-
-		// 		return conn, err
-		// 	},
-
-		// 	TestOnBorrow: func(conn redis.Conn, t time.Time) error {
-		// 		if time.Since(t) < (time.Second * 30) {
-		// 			return nil
-		// 		}
-		// 		_, err := conn.Do("PING")
-		// 		return err
-		// 	},
-		// 	Wait: opts.Wait,
-		// }
-		c.prefix = opts.Prefix
-		c.pool = client
-
-		c.Log = opts.Log
+		c.replicas = replicas
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		c.onStop("replica checker", func() {
+			cancel()
+			<-done
+		})
+		go replicas.run(ctx, done)
+	}
 
-		return nil
-	default:
-		return errors.New("unsupported options")
+	// 沒有設定 Logger 時使用 Log，只建立一次
+	c.logger = cfg.Logger
+	if c.logger == nil && c.Log != nil {
+		c.logger = NewStdLogger(c.Log, LevelInfo)
 	}
+	if cfg.Debug {
+		c.EnableDebugLog(DebugLogOptions{
+			Statement: cfg.LogStatement,
+			MaxLen:    cfg.LogMaxLen,
+		})
+	}
+
+	return nil
 }
 
 func (c *Cacher) clone() *Cacher {
@@ -174,9 +250,108 @@ func (c *Cacher) clone() *Cacher {
 	return &clone
 }
 
-// GracefulStop GracefulStop
+// GracefulStop 先停止背景工作(ex. WorkerPool)並等待其結束，再關閉連接池
 func (c *Cacher) GracefulStop() {
+	if c.stopper != nil {
+		c.stopper.stop()
+	}
+	c.closePools()
+}
+
+// closePools 關閉主庫、阻塞指令及從庫的連接池
+func (c *Cacher) closePools() {
 	c.pool.Close()
+	if c.blocking != nil {
+		c.blocking.Close()
+	}
+	if c.replicas != nil {
+		c.replicas.close()
+	}
+}
+
+// onStop 註冊 GracefulStop 時要執行的停止函式，name 用於 Shutdown 逾時時的錯誤訊息，返回取消註冊的函式
+func (c *Cacher) onStop(name string, fn func()) func() {
+	if c.stopper == nil {
+		return func() {}
+	}
+	return c.stopper.add(name, fn)
+}
+
+// stopper GracefulStop 時需要先停止的背景工作
+type stopper struct {
+	mu    sync.Mutex
+	seq   int
+	funcs map[int]stopFunc
+}
+
+type stopFunc struct {
+	name string
+	fn   func()
+}
+
+func (s *stopper) add(name string, fn func()) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.funcs == nil {
+		s.funcs = make(map[int]stopFunc)
+	}
+	s.seq++
+	id := s.seq
+	s.funcs[id] = stopFunc{name: name, fn: fn}
+
+	return func() {
+		s.mu.Lock()
+		delete(s.funcs, id)
+		s.mu.Unlock()
+	}
+}
+
+func (s *stopper) stop() {
+	s.stopContext(context.Background())
+}
+
+// stopContext 同時執行所有停止函式，返回 ctx 到期時尚未結束的工作名稱
+func (s *stopper) stopContext(ctx context.Context) []string {
+	s.mu.Lock()
+	funcs := s.funcs
+	s.funcs = nil
+	s.mu.Unlock()
+
+	var mu sync.Mutex
+	running := make(map[int]string, len(funcs))
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for id, f := range funcs {
+		running[id] = f.name
+		wg.Add(1)
+		go func(id int, f stopFunc) {
+			defer wg.Done()
+			f.fn()
+			mu.Lock()
+			delete(running, id)
+			mu.Unlock()
+		}(id, f)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(running))
+	for _, name := range running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // WithContext 添加context 進去
@@ -205,19 +380,33 @@ func (c *Cacher) Do(commandName string, args ...interface{}) *Cmd {
 	// conn := c.pool.Get()
 	// defer conn.Close()
 
-	if c.ctx.Context != nil {
-		traceID := c.ctx.Context.Value(c.ctx.Field)
-		log.Println("[Context] ", traceID, commandName, args)
-	}
 	argsNew := make([]interface{}, 1+len(args))
 	argsNew[0] = commandName
 	copy(argsNew[1:], args)
-	contextDefault := context.Background()
-	if c.ctx.Context != nil {
-		contextDefault = c.ctx.Context
+	// 降級中直接使用記憶體
+	if fallbackCmd, ok := c.fallbackDo(argsNew, nil); ok {
+		return fallbackCmd
+	}
+	ctx := c.withLogTrace(c.context())
+	client, replica := c.commandPool(argsNew)
+	contextDefault, client, cancel := c.commandClient(ctx, client, argsNew)
+	defer cancel()
+	goRedisCmd, retries := c.doWithRetry(contextDefault, client, argsNew)
+	if err := goRedisCmd.Err(); replica != nil && retryableError(err) {
+		// 從庫連線失敗時改由主庫執行
+		replica.markDown(err)
+		contextPrimary, primary, cancelPrimary := c.commandClient(ctx, c.pool, argsNew)
+		defer cancelPrimary()
+		goRedisCmd, retries = c.doWithRetry(contextPrimary, primary, argsNew)
+	}
+	if err := goRedisCmd.Err(); err != nil {
+		if fallbackCmd, ok := c.fallbackDo(argsNew, err); ok {
+			fallbackCmd.Retries = retries
+			return fallbackCmd
+		}
 	}
-	goRedisCmd := c.pool.Do(contextDefault, argsNew...)
 	cmd.cmd = goRedisCmd
+	cmd.Retries = retries
 	cmd.val = goRedisCmd.Val()
 	cmd.Err = goRedisCmd.Err()
 	if cmd.Err != nil && cmd.Err.Error() == "redis: nil" {
@@ -455,6 +644,23 @@ func (c *Cacher) BRPop(key string, timeout int) *Cmd {
 	}
 }
 
+// BRPopLPush 它是 RPOPLPUSH 命令的阻塞版本，從 source 列表尾部彈出元素並插入到 destination 列表頭部，返回該元素。
+// 超時參數 timeout 接受一個以秒為單位的數字作為值，超時時返回 ErrNil。
+func (c *Cacher) BRPopLPush(source, destination string, timeout int) *Cmd {
+	return c.Do("BRPOPLPUSH", c.getKey(source), c.getKey(destination), timeout)
+}
+
+// BLMove 它是 LMOVE 命令的阻塞版本(redis 6.2 以上)，srcPos 和 dstPos 為 LEFT 或 RIGHT。
+// 超時參數 timeout 接受一個以秒為單位的數字作為值，超時時返回 ErrNil。
+func (c *Cacher) BLMove(source, destination, srcPos, dstPos string, timeout int) *Cmd {
+	return c.Do("BLMOVE", c.getKey(source), c.getKey(destination), srcPos, dstPos, timeout)
+}
+
+// LRem 根據參數 count 的值，移除列表中與參數 value 相等的元素。
+func (c *Cacher) LRem(key string, count int64, value interface{}) *Cmd {
+	return c.Do("LREM", c.getKey(key), count, value)
+}
+
 // LPop 移出並獲取列表中的第一個元素（表頭，左邊）
 func (c *Cacher) LPop(key string) *Cmd {
 	return c.Do("LPOP", c.getKey(key))
@@ -534,6 +740,11 @@ func (c *Cacher) ZAdd(key string, score int64, member string) *Cmd {
 	return c.Do("ZADD", c.getKey(key), score, member)
 }
 
+// ZAddFloat 與 ZAdd 相同，score 為浮點數(例如毫秒時間戳或帶小數的分數)。
+func (c *Cacher) ZAddFloat(key string, score float64, member string) *Cmd {
+	return c.Do("ZADD", c.getKey(key), score, member)
+}
+
 // ZRem 移除有序集 key 中的一個成員，不存在的成員將被忽略。
 func (c *Cacher) ZRem(key string, member string) *Cmd {
 	return c.Do("ZREM", c.getKey(key), member)
@@ -618,52 +829,16 @@ func (c *Cacher) SMembers(key string) *Cmd {
 	return c.Do("SMEMBERS", c.getKey(key))
 }
 
-/**
-Redis 發布訂閱(pub/sub)是一種消息通信模式：發送者(pub)發送消息，訂閱者(sub)接收消息。
-Redis 客戶端可以訂閱任意數量的頻道。
-當有新消息通過 PUBLISH 命令發送給頻道 channel 時， 這個消息就會被發送給訂閱它的所有客戶端。
-**/
-
-// Publish 將信息發送到指定的頻道，返回接收到信息的訂閱者數量
-func (c *Cacher) Publish(channel, message string) error {
-	cmd := c.Do("PUBLISH", channel, message)
-
-	return cmd.Err
-}
-
-// Subscribe 訂閱給定的一個或多個頻道的信息。
-// 支持redis服務停止或網絡異常等情況時，自動重新訂閱。
-// 一般的程序都是啟動後開啟一些固定channel的訂閱，也不會動態的取消訂閱，這種場景下可以使用本方法。
-// 覆雜場景的使用可以直接參考 https://godoc.org/github.com/gomodule/redigo/redis#hdr-Publish_and_Subscribe
-func (c *Cacher) Subscribe(onMessage func(channel string, data []byte) error, channels ...string) error {
-	// conn := c.pool.Get()
-	// psc := redis.PubSubConn{Conn: conn}
-	// err := psc.Subscribe(redis.Args{}.AddFlat(channels)...)
-	// // 如果訂閱失敗，休息1秒後重新訂閱（比如當redis服務停止服務或網絡異常）
-	// if err != nil {
-	// 	fmt.Println(err)
-	// 	time.Sleep(time.Second)
-	// 	return c.Subscribe(onMessage, channels...)
-	// }
-	pubSub := c.pool.Subscribe(c.ctx.Context, channels...)
-	// 處理消息
-	ch := pubSub.Channel()
-	go func() {
-		for msg := range ch {
-			//如果redis斷線 會自己重連
-			go onMessage(msg.Channel, []byte(msg.Payload))
-
-		}
-	}()
-
-	return nil
-}
-
 // getKey 將健名加上指定的前綴。
 func (c *Cacher) getKey(key string) string {
 	return c.prefix + key
 }
 
+// trimKey 將健名去掉前綴，用在 redis 回傳的鍵名上。
+func (c *Cacher) trimKey(key string) string {
+	return strings.TrimPrefix(key, c.prefix)
+}
+
 // encode 序列化要保存的值
 func (c *Cacher) encode(val interface{}) (interface{}, error) {
 	var value interface{}
@@ -737,11 +912,9 @@ func (s *Script) DoScript(c *Cacher, keysAndArgs ...interface{}) (interface{}, e
 	// conn := c.pool.Get()
 	// defer conn.Close()
 
-	if c.ctx.Context != nil {
-		traceID := c.ctx.Context.Value(c.ctx.Field)
-		log.Println("[Context] ", traceID, keysAndArgs)
-	}
+	c, end := c.startSpan("redis.script", label.String("db.redis.script_sha", s.hash))
 	v := c.Do("EVAL", s.args(s.src, keysAndArgs)...)
+	end(v.Err)
 	// }
 
 	return v.val, v.Err
@@ -755,6 +928,7 @@ func (c *Cacher) Scan(cursor, count int, match string) *Cmd {
 // Mutex 包覆原本物件
 type Mutex struct {
 	mutexObject *redsync.Mutex
+	cacher      *Cacher
 }
 
 // MutexOption 包覆原本的option
@@ -765,7 +939,7 @@ type MutexOption struct {
 // NewMutex 產生新的Mutex
 func (c *Cacher) NewMutex(mutexName string, options ...MutexOption) *Mutex {
 
-	response := &Mutex{}
+	response := &Mutex{cacher: c}
 	response.mutexObject = c.syncRedis.NewMutex(mutexName)
 	for _, o := range options {
 		o.mutexOption.Apply(response.mutexObject)
@@ -776,13 +950,33 @@ func (c *Cacher) NewMutex(mutexName string, options ...MutexOption) *Mutex {
 
 // Lock 上鎖
 func (m *Mutex) Lock() error {
-	err := m.mutexObject.Lock()
-	return err
+	return m.lock(m.cacher)
 }
 
 // UnLock 解鎖並回傳bool
 func (m *Mutex) UnLock() (bool, error) {
-	unlockBool, err := m.mutexObject.Unlock()
+	return m.unlock(m.cacher)
+}
+
+// lock 以 c 的 context 上鎖，成功時登記到 lifecycle 以便關閉時釋放
+func (m *Mutex) lock(c *Cacher) error {
+	c, end := c.startSpan("redis.lock", label.String("db.redis.lock", m.mutexObject.Name()))
+	err := m.mutexObject.LockContext(c.context())
+	end(err)
+	if err == nil && m.cacher.lifecycle != nil {
+		m.cacher.lifecycle.addMutex(m)
+	}
+	return err
+}
+
+// unlock 以 c 的 context 解鎖，並從 lifecycle 移除
+func (m *Mutex) unlock(c *Cacher) (bool, error) {
+	c, end := c.startSpan("redis.unlock", label.String("db.redis.lock", m.mutexObject.Name()))
+	unlockBool, err := m.mutexObject.UnlockContext(c.context())
+	end(err)
+	if m.cacher.lifecycle != nil {
+		m.cacher.lifecycle.removeMutex(m)
+	}
 	return unlockBool, err
 }
 
@@ -854,21 +1048,17 @@ func WithValue(v string) MutexOption {
 
 // ScriptLoad 返回集合內的所有的成員
 func (c *Cacher) ScriptLoad(script string) (str string, err error) {
-	contextDefault := context.Background()
-	if c.ctx.Context != nil {
-		contextDefault = c.ctx.Context
-	}
-	str, err = c.pool.ScriptLoad(contextDefault, script).Result()
+	contextDefault, client, cancel := c.commandClient(c.withLogTrace(c.context()), c.pool, []interface{}{"SCRIPT"})
+	defer cancel()
+	str, err = client.ScriptLoad(contextDefault, script).Result()
 	return str, err
 }
 
 func (c *Cacher) EvalSha(script string, keys []string, args ...interface{}) *Cmd {
-	contextDefault := context.Background()
-	if c.ctx.Context != nil {
-		contextDefault = c.ctx.Context
-	}
+	contextDefault, client, cancel := c.commandClient(c.withLogTrace(c.context()), c.pool, []interface{}{"EVALSHA"})
+	defer cancel()
 	cmd := &Cmd{}
-	goRedisCmd := c.pool.EvalSha(contextDefault, script, keys, args...)
+	goRedisCmd := client.EvalSha(contextDefault, script, keys, args...)
 	cmd.cmd = goRedisCmd
 	cmd.val = goRedisCmd.Val()
 	cmd.Err = goRedisCmd.Err()
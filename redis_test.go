@@ -39,6 +39,37 @@ func init() {
 	redisCacher, _ = New(conf)
 }
 
+// newTestServer 產生測試結束時關閉的miniredis
+func newTestServer(t *testing.T) *miniredis.Miniredis {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis error:%s ", err)
+	}
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// newTestCacher 產生獨立的miniredis與Cacher，opts 可調整預設的 Options
+// miniredis 的lua腳本固定在db 0執行，有用到腳本的測試需使用db 0
+func newTestCacher(t *testing.T, opts ...func(*Options)) (*Cacher, *miniredis.Miniredis) {
+	s := newTestServer(t)
+	options := Options{
+		Addr:   s.Addr(),
+		Prefix: "RedisTest:",
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	c, err := New(options)
+	if err != nil {
+		t.Fatalf("New error:%s ", err)
+	}
+	t.Cleanup(c.GracefulStop)
+
+	return c, s
+}
+
 func TestNew(t *testing.T) {
 	// conf := Options{
 	// 	Addr:      "127.0.0.1:6379",
@@ -2673,9 +2704,11 @@ func TestCacher_Subscribe(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := redisCacher
-			if err := c.Subscribe(tt.args.onMessage, tt.args.channels...); err != nil {
-				t.Errorf("Cacher.Subscribe() error = %v", err)
+			sub, err := c.Subscribe(tt.args.onMessage, tt.args.channels...)
+			if err != nil {
+				t.Fatalf("Cacher.Subscribe() error = %v", err)
 			}
+			sub.Close()
 		})
 	}
 }
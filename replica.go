@@ -0,0 +1,223 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ReplicaRouting 唯讀指令選擇從庫的方式
+type ReplicaRouting int
+
+const (
+	// RouteRoundRobin 輪流使用健康的從庫
+	RouteRoundRobin ReplicaRouting = iota
+	// RouteLowestLatency 使用健康檢查延遲最低的從庫
+	RouteLowestLatency
+)
+
+// ReplicaOptions 從庫設定，設定 Addrs 後唯讀指令(GET、HGETALL、ZRANGE、SMEMBERS、LRANGE 等)改由從庫執行
+type ReplicaOptions struct {
+	Addrs            []string       // 從庫地址 host:port，帳密、DB、TLS 等設定與主庫相同
+	Routing          ReplicaRouting // 選擇從庫的方式，預設 RouteRoundRobin
+	CheckInterval    time.Duration  // 健康檢查間隔，預設1秒
+	FailureThreshold int            // 健康檢查連續失敗幾次視為不健康，預設2；指令連線失敗時立即視為不健康
+}
+
+// ReplicaStatus 從庫狀態
+type ReplicaStatus struct {
+	Addr      string
+	Healthy   bool
+	Latency   time.Duration // 健康檢查延遲的移動平均
+	LastError string
+}
+
+// replicaExcluded 雖然唯讀但不需要送到從庫的指令
+var replicaExcluded = map[string]bool{"PING": true, "ECHO": true}
+
+// Primary 產生唯讀指令也在主庫執行的 Cacher，用於寫入後需要立即讀到結果(read your writes)的情況
+func (c *Cacher) Primary() *Cacher {
+	clone := c.clone()
+	clone.usePrimary = true
+	return clone
+}
+
+// Replicas 返回各從庫的狀態，沒有設定從庫時返回 nil
+func (c *Cacher) Replicas() []ReplicaStatus {
+	if c.replicas == nil {
+		return nil
+	}
+	status := make([]ReplicaStatus, len(c.replicas.nodes))
+	for i, node := range c.replicas.nodes {
+		status[i] = node.status()
+	}
+	return status
+}
+
+// replicaFor 選擇執行指令的從庫，應在主庫執行時返回 nil
+func (c *Cacher) replicaFor(args []interface{}) *replicaNode {
+	if c.replicas == nil || c.usePrimary {
+		return nil
+	}
+	name := strings.ToUpper(formatArg(args[0]))
+	if !readCommands[name] || replicaExcluded[name] {
+		return nil
+	}
+	return c.replicas.pick()
+}
+
+type replicaSet struct {
+	opts  ReplicaOptions
+	nodes []*replicaNode
+	next  uint32
+}
+
+type replicaNode struct {
+	addr   string
+	client *redis.Client
+
+	mu        sync.Mutex
+	healthy   bool
+	failures  int
+	latency   time.Duration
+	lastError string
+}
+
+// newReplicaSet 以主庫的設定建立各從庫的連接池
+func newReplicaSet(cfg Config, primary *redis.Options, lc *lifecycle) (*replicaSet, error) {
+	opts := cfg.Replicas
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = time.Second
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 2
+	}
+
+	set := &replicaSet{opts: opts}
+	for _, addr := range opts.Addrs {
+		redisOption := *primary
+		redisOption.Addr = addr
+		if cfg.TLS != nil {
+			// ServerName 預設為從庫的主機
+			tlsConfig, err := cfg.TLS.tlsConfig(addr)
+			if err != nil {
+				set.close()
+				return nil, err
+			}
+			redisOption.TLSConfig = tlsConfig
+		}
+		client := redis.NewClient(&redisOption)
+		client.AddHook(lifecycleHook{lc})
+		set.nodes = append(set.nodes, &replicaNode{addr: addr, client: client, healthy: true})
+	}
+	return set, nil
+}
+
+// pick 依 Routing 選擇健康的從庫，都不健康時返回 nil
+func (s *replicaSet) pick() *replicaNode {
+	if s.opts.Routing == RouteLowestLatency {
+		var best *replicaNode
+		var bestLatency time.Duration
+		for _, node := range s.nodes {
+			node.mu.Lock()
+			healthy, latency := node.healthy, node.latency
+			node.mu.Unlock()
+			if healthy && (best == nil || latency < bestLatency) {
+				best, bestLatency = node, latency
+			}
+		}
+		return best
+	}
+
+	start := atomic.AddUint32(&s.next, 1)
+	for i := range s.nodes {
+		node := s.nodes[(int(start)+i)%len(s.nodes)]
+		if node.isHealthy() {
+			return node
+		}
+	}
+	return nil
+}
+
+// run 定期檢查各從庫直到 ctx 結束
+func (s *replicaSet) run(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.opts.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.check(ctx)
+		}
+	}
+}
+
+// check ping 各從庫並更新延遲及健康狀態
+func (s *replicaSet) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, node := range s.nodes {
+		wg.Add(1)
+		go func(node *replicaNode) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, s.opts.CheckInterval)
+			defer cancel()
+			start := time.Now()
+			err := node.client.Ping(ctx).Err()
+			node.report(time.Since(start), err, s.opts.FailureThreshold)
+		}(node)
+	}
+	wg.Wait()
+}
+
+func (s *replicaSet) close() {
+	for _, node := range s.nodes {
+		node.client.Close()
+	}
+}
+
+func (n *replicaNode) isHealthy() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.healthy
+}
+
+// report 記錄健康檢查結果，連續失敗 threshold 次視為不健康，成功一次即恢復
+func (n *replicaNode) report(latency time.Duration, err error, threshold int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err != nil {
+		n.failures++
+		n.lastError = err.Error()
+		if n.failures >= threshold {
+			n.healthy = false
+		}
+		return
+	}
+	n.failures = 0
+	n.healthy = true
+	if n.latency == 0 {
+		n.latency = latency
+	} else {
+		n.latency = (n.latency*7 + latency) / 8
+	}
+}
+
+// markDown 指令連線失敗時立即視為不健康，等健康檢查成功後恢復
+func (n *replicaNode) markDown(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.healthy = false
+	n.lastError = err.Error()
+}
+
+func (n *replicaNode) status() ReplicaStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return ReplicaStatus{Addr: n.addr, Healthy: n.healthy, Latency: n.latency, LastError: n.lastError}
+}
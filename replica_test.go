@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newReplicaCacher(t *testing.T, routing ReplicaRouting, n int) (*Cacher, *miniredis.Miniredis, []*miniredis.Miniredis) {
+	var replicas []*miniredis.Miniredis
+	var addrs []string
+	for i := 0; i < n; i++ {
+		s := newTestServer(t)
+		replicas = append(replicas, s)
+		addrs = append(addrs, s.Addr())
+	}
+	c, primary := newTestCacher(t, func(o *Options) {
+		o.Replicas = ReplicaOptions{Addrs: addrs, Routing: routing, CheckInterval: 10 * time.Millisecond}
+	})
+
+	return c, primary, replicas
+}
+
+func TestCacher_ReplicaRoundRobin(t *testing.T) {
+	c, primary, replicas := newReplicaCacher(t, RouteRoundRobin, 2)
+	primary.Set("RedisTest:replica-T1", "primary")
+	replicas[0].Set("RedisTest:replica-T1", "r0")
+	replicas[1].Set("RedisTest:replica-T1", "r1")
+
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		got, _ := c.Get("replica-T1").String()
+		seen[got]++
+	}
+	if seen["r0"] != 2 || seen["r1"] != 2 {
+		t.Errorf("reads = %v, want 2 on each replica", seen)
+	}
+	if got, _ := c.Primary().Get("replica-T1").String(); got != "primary" {
+		t.Errorf("Primary().Get() = %q, want primary", got)
+	}
+
+	c.Set("replica-T2", "w", 0)
+	if got, _ := primary.Get("RedisTest:replica-T2"); got != "w" {
+		t.Errorf("write went to %q, want primary", got)
+	}
+	if replicas[0].Exists("RedisTest:replica-T2") || replicas[1].Exists("RedisTest:replica-T2") {
+		t.Errorf("write reached a replica")
+	}
+}
+
+func TestCacher_ReplicaFailover(t *testing.T) {
+	c, primary, replicas := newReplicaCacher(t, RouteRoundRobin, 1)
+	primary.Set("RedisTest:replica-T1", "primary")
+	replicas[0].Set("RedisTest:replica-T1", "r0")
+
+	replicas[0].Close()
+	// 從庫連線失敗時改讀主庫
+	if got, err := c.Get("replica-T1").String(); err != nil || got != "primary" {
+		t.Errorf("Get() = %q, %v, want primary", got, err)
+	}
+	if status := c.Replicas(); len(status) != 1 || status[0].Healthy || status[0].LastError == "" {
+		t.Errorf("Replicas() = %+v, want unhealthy", status)
+	}
+	if got, _ := c.Get("replica-T1").String(); got != "primary" {
+		t.Errorf("Get() while replica down = %q, want primary", got)
+	}
+
+	if err := replicas[0].Restart(); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	// go-redis 連續撥號失敗後每秒才重試一次
+	if !waitFor(3*time.Second, func() bool { return c.Replicas()[0].Healthy }) {
+		t.Fatalf("replica still unhealthy after restart")
+	}
+	if got, _ := c.Get("replica-T1").String(); got != "r0" {
+		t.Errorf("Get() after recovery = %q, want r0", got)
+	}
+}
+
+func TestReplicaSet_LowestLatency(t *testing.T) {
+	set := &replicaSet{opts: ReplicaOptions{Routing: RouteLowestLatency}}
+	for _, latency := range []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond} {
+		set.nodes = append(set.nodes, &replicaNode{healthy: true, latency: latency})
+	}
+	if got := set.pick(); got != set.nodes[1] {
+		t.Errorf("pick() latency = %v, want 1ms", got.latency)
+	}
+	set.nodes[1].report(0, errWrongType, 1)
+	if got := set.pick(); got != set.nodes[2] {
+		t.Errorf("pick() after failure latency = %v, want 2ms", got.latency)
+	}
+	for _, node := range set.nodes {
+		node.markDown(errWrongType)
+	}
+	if got := set.pick(); got != nil {
+		t.Errorf("pick() with no healthy replica = %v, want nil", got)
+	}
+}
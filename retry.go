@@ -0,0 +1,158 @@
+package redis
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RetryPolicy 依指令決定是否重試，只重試冪等的指令或明確標記的指令。
+// 未設定時以 Options.MaxRetries 及預設的退避時間套用，go-redis 本身不分指令的重試一律關閉
+type RetryPolicy struct {
+	MaxRetries int           // 最多重試次數，預設3
+	MinBackoff time.Duration // 第一次重試前的等待時間，之後每次加倍，預設8ms
+	MaxBackoff time.Duration // 等待時間上限，預設512ms
+	Jitter     float64       // 等待時間隨機減少的比例(0~1]，預設0.2，小於0時不加
+	Commands   []string      // 額外視為可重試的指令，例如 "INCRBY"
+}
+
+// idempotentWrites 重複執行結果相同的寫入指令，唯讀指令見 readCommands
+var idempotentWrites = map[string]bool{
+	"SET": true, "SETEX": true, "PSETEX": true, "MSET": true, "DEL": true, "UNLINK": true,
+	"EXPIRE": true, "PEXPIRE": true, "EXPIREAT": true, "PEXPIREAT": true, "PERSIST": true,
+	"HSET": true, "HMSET": true, "HDEL": true, "SADD": true, "SREM": true, "ZREM": true,
+	"XACK": true, "XGROUP": true, "SCRIPT": true, "SELECT": true,
+}
+
+// isIdempotent 判斷指令是否可安全重試，ZADD 帶 INCR 時不可重試
+func isIdempotent(args []interface{}) bool {
+	if len(args) == 0 {
+		return false
+	}
+	name := strings.ToUpper(formatArg(args[0]))
+	if name == "ZADD" {
+		for _, arg := range args[2:] {
+			if strings.EqualFold(formatArg(arg), "INCR") {
+				return false
+			}
+		}
+		return true
+	}
+	return readCommands[name] || idempotentWrites[name]
+}
+
+// Idempotent 產生將所有指令視為可重試的 Cacher，用於呼叫端確定重複執行沒有副作用的情況
+func (c *Cacher) Idempotent() *Cacher {
+	clone := c.clone()
+	clone.forceRetry = true
+	return clone
+}
+
+// retryable 判斷指令是否可依 RetryPolicy 重試
+func (c *Cacher) retryable(args []interface{}) bool {
+	if c.retry == nil || len(args) == 0 {
+		return false
+	}
+	if c.forceRetry || isIdempotent(args) {
+		return true
+	}
+	name := formatArg(args[0])
+	for _, cmd := range c.retry.Commands {
+		if strings.EqualFold(cmd, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// doWithRetry 執行指令，可重試的指令遇到連線類錯誤時依退避時間重試，返回結果及重試次數。
+// 等待時會超過 ctx 的 deadline 時不再重試
+func (c *Cacher) doWithRetry(ctx context.Context, client *redis.Client, args []interface{}) (*redis.Cmd, int) {
+	cmd := client.Do(ctx, args...)
+	if !c.retryable(args) {
+		return cmd, 0
+	}
+
+	retries := 0
+	for retries < c.retry.MaxRetries && retryableError(cmd.Err()) {
+		wait := c.retry.backoff(retries)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			break
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return cmd, retries
+		case <-timer.C:
+		}
+		retries++
+		cmd = client.Do(ctx, args...)
+	}
+
+	return cmd, retries
+}
+
+// withDefaults 補上預設值
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries <= 0 {
+		p.MaxRetries = 3
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = 8 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 512 * time.Millisecond
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = p.MinBackoff
+	}
+	if p.Jitter == 0 {
+		p.Jitter = 0.2
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	return p
+}
+
+// backoff 第 attempt 次(從0開始)重試前的等待時間
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 0; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// retryableError 連線中斷、逾時及伺服器暫時無法處理的錯誤可以重試
+func retryableError(err error) bool {
+	switch err {
+	case nil, redis.Nil, context.Canceled, context.DeadlineExceeded, ErrCircuitOpen:
+		return false
+	case io.EOF, io.ErrUnexpectedEOF:
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+
+	s := err.Error()
+	for _, prefix := range []string{"LOADING ", "READONLY ", "CLUSTERDOWN ", "TRYAGAIN ", "MASTERDOWN "} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return s == "ERR max number of clients reached" || s == "redis: connection pool timeout"
+}
@@ -0,0 +1,157 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// restartLater 關閉 miniredis，經過 d 後重新啟動
+func restartLater(t *testing.T, s *miniredis.Miniredis, d time.Duration) <-chan struct{} {
+	s.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		time.Sleep(d)
+		if err := s.Restart(); err != nil {
+			t.Errorf("Restart() error = %v", err)
+		}
+	}()
+	return done
+}
+
+func TestCacher_RetryPolicy(t *testing.T) {
+	c, s := newTestCacher(t, func(o *Options) {
+		o.Retry = &RetryPolicy{MaxRetries: 10, MinBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, Jitter: -1}
+	})
+	c.Set("retry-T1", "a", 0)
+
+	done := restartLater(t, s, 30*time.Millisecond)
+	cmd := c.Get("retry-T1")
+	<-done
+	if cmd.Err != nil {
+		t.Fatalf("Get() error = %v", cmd.Err)
+	}
+	if cmd.Retries == 0 {
+		t.Errorf("Retries = 0, want > 0")
+	}
+	if got, _ := cmd.String(); got != "a" {
+		t.Errorf("Get() = %q, want a", got)
+	}
+}
+
+func TestCacher_RetryNonIdempotent(t *testing.T) {
+	c, s := newTestCacher(t, func(o *Options) {
+		o.Retry = &RetryPolicy{MaxRetries: 10, MinBackoff: 10 * time.Millisecond, Jitter: -1}
+	})
+
+	done := restartLater(t, s, 30*time.Millisecond)
+	cmd := c.Do("INCR", c.getKey("retry-T2"))
+	if cmd.Err == nil || cmd.Retries != 0 {
+		t.Errorf("INCR error = %v, retries = %d, want error without retry", cmd.Err, cmd.Retries)
+	}
+	<-done
+
+	done = restartLater(t, s, 30*time.Millisecond)
+	cmd = c.Idempotent().Do("INCR", c.getKey("retry-T2"))
+	<-done
+	if cmd.Err != nil || cmd.Retries == 0 {
+		t.Errorf("Idempotent INCR error = %v, retries = %d", cmd.Err, cmd.Retries)
+	}
+}
+
+func TestCacher_DefaultRetry(t *testing.T) {
+	c, s := newTestCacher(t)
+	c.Set("retry-T4", "a", 0)
+
+	done := restartLater(t, s, 10*time.Millisecond)
+	if cmd := c.IncrBy("retry-T5", 1); cmd.Err == nil {
+		t.Errorf("IncrBy() error = nil, want no retry by default")
+	}
+	cmd := c.Get("retry-T4")
+	<-done
+	if cmd.Err != nil || cmd.Retries == 0 {
+		t.Errorf("Get() error = %v, retries = %d, want retried by default", cmd.Err, cmd.Retries)
+	}
+	// go-redis 將 -1 轉為 0，表示不重試
+	if c.pool.Options().MaxRetries != 0 {
+		t.Errorf("go-redis MaxRetries = %d, want 0", c.pool.Options().MaxRetries)
+	}
+
+	noRetry, _ := newTestCacher(t, func(o *Options) { o.MaxRetries = -1 })
+	if noRetry.retry != nil {
+		t.Errorf("MaxRetries -1 retry = %+v, want nil", noRetry.retry)
+	}
+}
+
+func TestCacher_RetryDeadline(t *testing.T) {
+	c, s := newTestCacher(t, func(o *Options) {
+		o.Retry = &RetryPolicy{MaxRetries: 10, MinBackoff: 200 * time.Millisecond, Jitter: -1}
+	})
+	s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	cmd := c.WithContext(ctx, "traceID").Get("retry-T3")
+	if cmd.Err == nil || cmd.Retries != 0 {
+		t.Errorf("Get() error = %v, retries = %d", cmd.Err, cmd.Retries)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("Get() took %v, want stop before deadline", d)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Jitter: -1}.withDefaults()
+	for i, want := range []time.Duration{10, 20, 40, 50, 50} {
+		if got := p.backoff(i); got != want*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", i, got, want*time.Millisecond)
+		}
+	}
+
+	p = RetryPolicy{MinBackoff: 10 * time.Millisecond, Jitter: 0.5}.withDefaults()
+	for i := 0; i < 20; i++ {
+		if got := p.backoff(0); got < 5*time.Millisecond || got > 10*time.Millisecond {
+			t.Errorf("backoff(0) with jitter = %v", got)
+		}
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want bool
+	}{
+		{[]interface{}{"get", "k"}, true},
+		{[]interface{}{"SET", "k", "v"}, true},
+		{[]interface{}{"incrby", "k", 1}, false},
+		{[]interface{}{"lpush", "k", "v"}, false},
+		{[]interface{}{"rpop", "k"}, false},
+		{[]interface{}{"zadd", "k", 1, "m"}, true},
+		{[]interface{}{"zadd", "k", "incr", 1, "m"}, false},
+	}
+	for _, tt := range tests {
+		if got := isIdempotent(tt.args); got != tt.want {
+			t.Errorf("isIdempotent(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRetryableError(t *testing.T) {
+	for err, want := range map[error]bool{
+		io.EOF:                        true,
+		errors.New("LOADING dataset"): true,
+		errors.New("ERR wrong type"):  false,
+		ErrCircuitOpen:                false,
+		context.Canceled:              false,
+	} {
+		if got := retryableError(err); got != want {
+			t.Errorf("retryableError(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
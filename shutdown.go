@@ -0,0 +1,202 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrShuttingDown Shutdown 後不再接受新的指令
+var ErrShuttingDown = errors.New("shutdown: client is shutting down")
+
+// ShutdownError Shutdown 到期時仍在執行的工作
+type ShutdownError struct {
+	Err     error    // ctx 的錯誤，鎖釋放失敗而 ctx 未到期時為 nil
+	Running []string // 未結束的背景工作、執行中的指令及未釋放的鎖
+}
+
+// Error 實作 error
+func (e *ShutdownError) Error() string {
+	msg := "shutdown: still running: " + strings.Join(e.Running, ", ")
+	if e.Err != nil {
+		msg += " (" + e.Err.Error() + ")"
+	}
+	return msg
+}
+
+// Unwrap 返回 ctx 的錯誤
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// Shutdown 依序停止背景工作(訂閱、WorkerPool、Consume 等)並等待處理中的訊息完成、
+// 停止接受新指令並等待執行中的指令、釋放持有的鎖，最後關閉連接池。
+// ctx 到期時仍會關閉連接池，並以 *ShutdownError 返回尚未結束的工作
+func (c *Cacher) Shutdown(ctx context.Context) error {
+	var running []string
+	if c.stopper != nil {
+		running = append(running, c.stopper.stopContext(ctx)...)
+	}
+	if c.lifecycle != nil {
+		c.lifecycle.close()
+		running = append(running, c.lifecycle.wait(ctx)...)
+		running = append(running, c.lifecycle.releaseMutexes(ctx)...)
+	}
+	c.closePools()
+
+	if len(running) > 0 {
+		return &ShutdownError{Err: ctx.Err(), Running: running}
+	}
+	return nil
+}
+
+type lifecycleKey struct{}
+
+type shutdownBypassKey struct{}
+
+// lifecycle 記錄執行中的指令及持有的鎖，WithContext 產生的 Cacher 共用
+type lifecycle struct {
+	mu        sync.Mutex
+	closing   bool
+	seq       uint64
+	inflight  map[uint64]string
+	drained   chan struct{}
+	isDrained bool
+	mutexes   map[*Mutex]struct{}
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		inflight: make(map[uint64]string),
+		drained:  make(chan struct{}),
+		mutexes:  make(map[*Mutex]struct{}),
+	}
+}
+
+// close 停止接受新指令
+func (l *lifecycle) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closing {
+		return
+	}
+	l.closing = true
+	l.checkDrained()
+}
+
+// checkDrained 停止接受新指令且沒有執行中的指令時通知 wait，需持有 mu
+func (l *lifecycle) checkDrained() {
+	if l.closing && !l.isDrained && len(l.inflight) == 0 {
+		l.isDrained = true
+		close(l.drained)
+	}
+}
+
+// wait 等待執行中的指令結束，返回到期時仍在執行的指令
+func (l *lifecycle) wait(ctx context.Context) []string {
+	select {
+	case <-l.drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	running := make([]string, 0, len(l.inflight))
+	for _, name := range l.inflight {
+		running = append(running, "command "+name)
+	}
+	sort.Strings(running)
+	return running
+}
+
+func (l *lifecycle) begin(ctx context.Context, name string) (context.Context, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closing && ctx.Value(shutdownBypassKey{}) == nil {
+		return ctx, ErrShuttingDown
+	}
+	l.seq++
+	l.inflight[l.seq] = name
+	return context.WithValue(ctx, lifecycleKey{}, l.seq), nil
+}
+
+func (l *lifecycle) end(ctx context.Context) {
+	id, ok := ctx.Value(lifecycleKey{}).(uint64)
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.inflight[id]; !ok {
+		return
+	}
+	delete(l.inflight, id)
+	l.checkDrained()
+}
+
+func (l *lifecycle) addMutex(m *Mutex) {
+	l.mu.Lock()
+	l.mutexes[m] = struct{}{}
+	l.mu.Unlock()
+}
+
+func (l *lifecycle) removeMutex(m *Mutex) {
+	l.mu.Lock()
+	delete(l.mutexes, m)
+	l.mu.Unlock()
+}
+
+// releaseMutexes 釋放 Lock 後尚未 UnLock 的鎖，返回釋放失敗的鎖
+func (l *lifecycle) releaseMutexes(ctx context.Context) []string {
+	l.mu.Lock()
+	mutexes := make([]*Mutex, 0, len(l.mutexes))
+	for m := range l.mutexes {
+		mutexes = append(mutexes, m)
+	}
+	l.mu.Unlock()
+
+	var held []string
+	ctx = context.WithValue(ctx, shutdownBypassKey{}, true)
+	for _, m := range mutexes {
+		if _, err := m.mutexObject.UnlockContext(ctx); err != nil {
+			held = append(held, "mutex "+m.mutexObject.Name())
+			continue
+		}
+		l.removeMutex(m)
+	}
+	sort.Strings(held)
+	return held
+}
+
+// lifecycleHook 以 go-redis 的 hook 記錄執行中的指令，Shutdown 後拒絕新的指令
+type lifecycleHook struct {
+	l *lifecycle
+}
+
+func (h lifecycleHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.l.begin(ctx, strings.ToUpper(cmd.Name()))
+}
+
+func (h lifecycleHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.l.end(ctx)
+	return nil
+}
+
+func (h lifecycleHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return h.l.begin(ctx, "PIPELINE")
+}
+
+func (h lifecycleHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	h.l.end(ctx)
+	return nil
+}
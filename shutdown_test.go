@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacher_Shutdown(t *testing.T) {
+	c, s := newTestCacher(t)
+
+	m := c.NewMutex("shutdown-lock")
+	if err := m.Lock(); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	var handled int32
+	sub, err := c.Subscribe(func(string, []byte) error {
+		time.Sleep(30 * time.Millisecond)
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, "shutdown-ch")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	c.Publish("shutdown-ch", "a")
+	if !waitFor(time.Second, func() bool { return sub.Stats().Received == 1 }) {
+		t.Fatalf("message not received")
+	}
+
+	popped := make(chan *Cmd)
+	go func() {
+		popped <- c.Do("BLPOP", c.getKey("shutdown-list"), 5)
+	}()
+	waitFor(time.Second, func() bool {
+		c.lifecycle.mu.Lock()
+		defer c.lifecycle.mu.Unlock()
+		return len(c.lifecycle.inflight) == 1
+	})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.Lpush(c.getKey("shutdown-list"), "job")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if atomic.LoadInt32(&handled) != 1 {
+		t.Errorf("subscription handler not finished")
+	}
+	if cmd := <-popped; cmd.Err != nil {
+		t.Errorf("BLPOP error = %v", cmd.Err)
+	}
+	if s.Exists("shutdown-lock") {
+		t.Errorf("mutex not released")
+	}
+	if err := c.Get("shutdown-T1").Err; err != ErrShuttingDown {
+		t.Errorf("Get() after Shutdown error = %v, want ErrShuttingDown", err)
+	}
+}
+
+func TestCacher_ShutdownDeadline(t *testing.T) {
+	c, _ := newTestCacher(t)
+
+	release := make(chan struct{})
+	defer close(release)
+	if _, err := c.Subscribe(func(string, []byte) error {
+		<-release
+		return nil
+	}, "shutdown-stuck"); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	c.Publish("shutdown-stuck", "a")
+
+	go c.Do("BLPOP", c.getKey("shutdown-empty"), 1)
+	waitFor(time.Second, func() bool {
+		c.lifecycle.mu.Lock()
+		defer c.lifecycle.mu.Unlock()
+		return len(c.lifecycle.inflight) == 1
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.Shutdown(ctx)
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() error = %v, want *ShutdownError", err)
+	}
+	got := strings.Join(shutdownErr.Running, ",")
+	if !strings.Contains(got, "subscription shutdown-stuck") || !strings.Contains(got, "command BLPOP") {
+		t.Errorf("Running = %v", shutdownErr.Running)
+	}
+}
@@ -0,0 +1,373 @@
+package redis
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// SlowLogOptions 慢指令及熱鍵統計設定
+type SlowLogOptions struct {
+	Threshold     time.Duration // 耗時超過時記錄，預設100ms
+	Capacity      int           // 保留最近的慢指令筆數，預設128
+	SampleRate    float64       // 熱鍵統計的取樣比例(0~1]，預設0.01，小於0時不統計
+	MaxKeys       int           // 熱鍵統計最多追蹤的鍵數，超過時淘汰次數最少的鍵，預設1000
+	TopK          int           // Report 返回的熱鍵數量，預設10
+	ServerSlowLog int64         // Report 時一併取得伺服器 SLOWLOG GET 的筆數，0 表示不取得
+}
+
+// SlowEntry 慢指令紀錄
+type SlowEntry struct {
+	Source     string        // "client" 或 "server"
+	Command    string        // 指令，除鍵名外的參數以 ? 代替
+	Key        string        // 鍵名，沒有鍵的指令為空
+	Duration   time.Duration // 耗時，client 為往返時間，server 為伺服器執行時間
+	Caller     string        // 呼叫位置 file:line，server 紀錄為空
+	Time       time.Time     // 發生時間
+	ClientAddr string        // server 紀錄的客戶端位址
+}
+
+// HotKey 熱鍵統計
+type HotKey struct {
+	Key   string
+	Count int64 // 取樣到的次數，估計的實際次數約為 Count / SampleRate
+}
+
+// SlowLogReport 慢指令及熱鍵報告
+type SlowLogReport struct {
+	Slow    []SlowEntry // 依時間由新到舊，包含 client 及 server 紀錄
+	HotKeys []HotKey    // 依次數由多到少
+}
+
+// SlowLog 記錄客戶端的慢指令及熱鍵，由 EnableSlowLog 產生
+type SlowLog struct {
+	cacher *Cacher
+	opts   SlowLogOptions
+
+	mu      sync.Mutex
+	entries []SlowEntry
+	next    int
+	keys    map[string]*hotKeyItem
+	minKeys hotKeyHeap // 依次數排序的最小堆積，淘汰時取次數最少的鍵
+}
+
+// EnableSlowLog 記錄耗時超過 Threshold 的指令及其呼叫位置，並取樣統計存取最頻繁的鍵，需在開始執行指令前呼叫
+func (c *Cacher) EnableSlowLog(opts SlowLogOptions) *SlowLog {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 100 * time.Millisecond
+	}
+	if opts.Capacity <= 0 {
+		opts.Capacity = 128
+	}
+	if opts.SampleRate == 0 {
+		opts.SampleRate = 0.01
+	}
+	if opts.SampleRate > 1 {
+		opts.SampleRate = 1
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 1000
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+
+	l := &SlowLog{
+		cacher:  c,
+		opts:    opts,
+		entries: make([]SlowEntry, 0, opts.Capacity),
+		keys:    make(map[string]*hotKeyItem),
+	}
+	c.addRedisHook(&slowLogHook{log: l})
+
+	return l
+}
+
+// Entries 取得客戶端的慢指令，依時間由新到舊
+func (l *SlowLog) Entries() []SlowEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]SlowEntry, 0, len(l.entries))
+	for i := 1; i <= len(l.entries); i++ {
+		entries = append(entries, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return entries
+}
+
+// HotKeys 取得取樣次數最多的 n 個鍵
+func (l *SlowLog) HotKeys(n int) []HotKey {
+	l.mu.Lock()
+	keys := make([]HotKey, 0, len(l.keys))
+	for k, item := range l.keys {
+		keys = append(keys, HotKey{Key: k, Count: item.count})
+	}
+	l.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if n > 0 && len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// Reset 清除所有紀錄
+func (l *SlowLog) Reset() {
+	l.mu.Lock()
+	l.entries = l.entries[:0]
+	l.next = 0
+	l.keys = make(map[string]*hotKeyItem)
+	l.minKeys = nil
+	l.mu.Unlock()
+}
+
+// Report 產生報告，設定 ServerSlowLog 時一併取得伺服器的 SLOWLOG，
+// 取得失敗時仍返回客戶端的報告及錯誤
+func (l *SlowLog) Report() (*SlowLogReport, error) {
+	report := &SlowLogReport{
+		Slow:    l.Entries(),
+		HotKeys: l.HotKeys(l.opts.TopK),
+	}
+	if l.opts.ServerSlowLog <= 0 {
+		return report, nil
+	}
+
+	logs, err := l.cacher.pool.SlowLogGet(l.cacher.context(), l.opts.ServerSlowLog).Result()
+	if err != nil {
+		return report, err
+	}
+	for _, s := range logs {
+		args := make([]interface{}, len(s.Args))
+		for i, arg := range s.Args {
+			args[i] = arg
+		}
+		report.Slow = append(report.Slow, SlowEntry{
+			Source:     "server",
+			Command:    commandStatement(args, StatementRedacted, 256),
+			Key:        commandKey(args),
+			Duration:   s.Duration,
+			Time:       s.Time,
+			ClientAddr: s.ClientAddr,
+		})
+	}
+	sort.SliceStable(report.Slow, func(i, j int) bool {
+		return report.Slow[i].Time.After(report.Slow[j].Time)
+	})
+
+	return report, nil
+}
+
+func (l *SlowLog) record(entry SlowEntry) {
+	l.mu.Lock()
+	if len(l.entries) < l.opts.Capacity {
+		l.entries = append(l.entries, entry)
+	} else {
+		l.entries[l.next] = entry
+	}
+	l.next = (l.next + 1) % l.opts.Capacity
+	l.mu.Unlock()
+}
+
+// sample 依取樣比例累計鍵的次數，追蹤的鍵數已滿時以新鍵取代堆積頂端次數最少的鍵(space-saving)
+func (l *SlowLog) sample(key string) {
+	if key == "" || l.opts.SampleRate < 0 || (l.opts.SampleRate < 1 && rand.Float64() >= l.opts.SampleRate) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if item, ok := l.keys[key]; ok {
+		item.count++
+		heap.Fix(&l.minKeys, item.index)
+		return
+	}
+	if len(l.keys) < l.opts.MaxKeys {
+		item := &hotKeyItem{key: key, count: 1}
+		l.keys[key] = item
+		heap.Push(&l.minKeys, item)
+		return
+	}
+	item := l.minKeys[0]
+	delete(l.keys, item.key)
+	item.key = key
+	item.count++
+	l.keys[key] = item
+	heap.Fix(&l.minKeys, 0)
+}
+
+// hotKeyItem 熱鍵統計的鍵及其在堆積中的位置
+type hotKeyItem struct {
+	key   string
+	count int64
+	index int
+}
+
+// hotKeyHeap 以次數排序的最小堆積，實作 heap.Interface
+type hotKeyHeap []*hotKeyItem
+
+func (h hotKeyHeap) Len() int           { return len(h) }
+func (h hotKeyHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h hotKeyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *hotKeyHeap) Push(x interface{}) {
+	item := x.(*hotKeyItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *hotKeyHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// keylessCommands 沒有鍵名參數的指令
+var keylessCommands = map[string]bool{
+	"PING": true, "ECHO": true, "SELECT": true, "AUTH": true, "HELLO": true, "QUIT": true,
+	"INFO": true, "CONFIG": true, "CLIENT": true, "SLOWLOG": true, "SCRIPT": true, "TIME": true,
+	"DBSIZE": true, "FLUSHDB": true, "FLUSHALL": true, "MULTI": true, "EXEC": true, "DISCARD": true,
+	"PUBLISH": true, "PUBSUB": true, "SUBSCRIBE": true, "PSUBSCRIBE": true, "UNSUBSCRIBE": true, "PUNSUBSCRIBE": true,
+}
+
+// commandKey 取得指令的第一個鍵名，EVAL/EVALSHA 取 KEYS 的第一個，XREAD/XREADGROUP 取 STREAMS 後的第一個
+func commandKey(args []interface{}) string {
+	if len(args) < 2 {
+		return ""
+	}
+	name := strings.ToUpper(formatArg(args[0]))
+	switch {
+	case keylessCommands[name]:
+		return ""
+	case name == "EVAL" || name == "EVALSHA":
+		if len(args) < 4 {
+			return ""
+		}
+		if n, _ := strconv.Atoi(formatArg(args[2])); n <= 0 {
+			return ""
+		}
+		return formatArg(args[3])
+	case name == "XREAD" || name == "XREADGROUP":
+		for i := 1; i < len(args)-1; i++ {
+			if strings.EqualFold(formatArg(args[i]), "STREAMS") {
+				return formatArg(args[i+1])
+			}
+		}
+		return ""
+	default:
+		return formatArg(args[1])
+	}
+}
+
+// packagePath 本套件的 import path，用來在呼叫堆疊中略過套件內部的函式
+var packagePath = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+1+strings.Index(name[slash+1:], ".")]
+}()
+
+// commandCaller 找出呼叫堆疊中第一個不屬於本套件、go-redis 及 redsync 的位置
+func commandCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, "github.com/go-redis/") ||
+			strings.HasPrefix(frame.Function, "github.com/go-redsync/") ||
+			(strings.HasPrefix(frame.Function, packagePath+".") && !strings.HasSuffix(frame.File, "_test.go"))
+		if !internal && frame.File != "" {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+type slowLogStartKey struct{}
+
+// slowLogHook 以 go-redis 的 hook 記錄慢指令及熱鍵
+type slowLogHook struct {
+	log *SlowLog
+}
+
+func (h *slowLogHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, slowLogStartKey{}, time.Now()), nil
+}
+
+func (h *slowLogHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	key := commandKey(cmd.Args())
+	h.log.sample(key)
+	// 阻塞指令的耗時主要是等待資料，不算慢指令
+	if _, blocking := blockDuration(cmd.Args()); !blocking {
+		h.check(ctx, commandStatement(cmd.Args(), StatementRedacted, 256), key)
+	}
+
+	return nil
+}
+
+func (h *slowLogHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, slowLogStartKey{}, time.Now()), nil
+}
+
+func (h *slowLogHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	statements := make([]string, len(cmds))
+	var firstKey string
+	var blocking bool
+	for i, cmd := range cmds {
+		key := commandKey(cmd.Args())
+		h.log.sample(key)
+		if firstKey == "" {
+			firstKey = key
+		}
+		if _, ok := blockDuration(cmd.Args()); ok {
+			blocking = true
+		}
+		statements[i] = commandStatement(cmd.Args(), StatementRedacted, 256)
+	}
+	if !blocking {
+		h.check(ctx, strings.Join(statements, "; "), firstKey)
+	}
+
+	return nil
+}
+
+func (h *slowLogHook) check(ctx context.Context, statement, key string) {
+	start, ok := ctx.Value(slowLogStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	d := time.Since(start)
+	if d < h.log.opts.Threshold {
+		return
+	}
+	h.log.record(SlowEntry{
+		Source:   "client",
+		Command:  statement,
+		Key:      key,
+		Duration: d,
+		Caller:   commandCaller(),
+		Time:     start,
+	})
+}
@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacher_EnableSlowLog(t *testing.T) {
+	c, _ := newTestCacher(t)
+	l := c.EnableSlowLog(SlowLogOptions{Threshold: time.Nanosecond, Capacity: 2, SampleRate: 1})
+
+	c.Set("slow-T1", "a", 0)
+	c.Get("slow-T1")
+	c.Get("slow-T1")
+	c.Get("slow-T2")
+
+	entries := l.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("len(Entries()) = %d, want 2", len(entries))
+	}
+	e := entries[0]
+	if e.Source != "client" || e.Command != "GET RedisTest:slow-T2" || e.Key != "RedisTest:slow-T2" {
+		t.Errorf("Entries()[0] = %+v", e)
+	}
+	if !strings.Contains(e.Caller, "slowlog_test.go:") {
+		t.Errorf("Caller = %q, want slowlog_test.go", e.Caller)
+	}
+	if e.Duration <= 0 || e.Time.IsZero() {
+		t.Errorf("Duration = %v, Time = %v", e.Duration, e.Time)
+	}
+
+	keys := l.HotKeys(1)
+	if len(keys) != 1 || keys[0].Key != "RedisTest:slow-T1" || keys[0].Count != 3 {
+		t.Errorf("HotKeys(1) = %+v", keys)
+	}
+
+	l.Reset()
+	if len(l.Entries()) != 0 || len(l.HotKeys(0)) != 0 {
+		t.Errorf("Reset() did not clear")
+	}
+}
+
+func TestSlowLog_Threshold(t *testing.T) {
+	c, _ := newTestCacher(t)
+	l := c.EnableSlowLog(SlowLogOptions{Threshold: time.Hour, SampleRate: -1})
+
+	c.Set("slow-T3", "a", 0)
+	if n := len(l.Entries()); n != 0 {
+		t.Errorf("len(Entries()) = %d, want 0", n)
+	}
+	if n := len(l.HotKeys(0)); n != 0 {
+		t.Errorf("len(HotKeys()) = %d, want 0", n)
+	}
+}
+
+func TestSlowLog_Blocking(t *testing.T) {
+	c, _ := newTestCacher(t)
+	l := c.EnableSlowLog(SlowLogOptions{Threshold: time.Nanosecond, SampleRate: -1})
+
+	// 阻塞指令的耗時是等待資料的時間，不記錄為慢指令
+	c.BLPop("slow-T5", 1)
+	if entries := l.Entries(); len(entries) != 0 {
+		t.Errorf("Entries() = %+v, want none for BLPOP", entries)
+	}
+	c.Get("slow-T5")
+	if n := len(l.Entries()); n != 1 {
+		t.Errorf("len(Entries()) = %d, want 1", n)
+	}
+}
+
+func TestSlowLog_MaxKeysEviction(t *testing.T) {
+	c, _ := newTestCacher(t)
+	l := c.EnableSlowLog(SlowLogOptions{Threshold: time.Hour, SampleRate: 1, MaxKeys: 3})
+
+	for _, key := range []string{"a", "a", "a", "b", "b", "c", "d", "d", "e"} {
+		l.sample(key)
+	}
+	// c(1) 被 d 取代成 2，d 增加為 3，最後 b(2) 被 e 取代成 3
+	want := []HotKey{{"a", 3}, {"d", 3}, {"e", 3}}
+	if got := l.HotKeys(0); !reflect.DeepEqual(got, want) {
+		t.Errorf("HotKeys() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSlowLog_MaxKeys(t *testing.T) {
+	c, _ := newTestCacher(t)
+	l := c.EnableSlowLog(SlowLogOptions{Threshold: time.Hour, SampleRate: 1, MaxKeys: 2})
+
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	c.Get("c")
+
+	keys := l.HotKeys(0)
+	if len(keys) != 2 || keys[0].Key != "RedisTest:a" || keys[1].Key != "RedisTest:c" || keys[1].Count != 2 {
+		t.Errorf("HotKeys() = %+v", keys)
+	}
+}
+
+func TestSlowLog_Report(t *testing.T) {
+	c, _ := newTestCacher(t)
+	l := c.EnableSlowLog(SlowLogOptions{Threshold: time.Nanosecond, SampleRate: 1, ServerSlowLog: 10})
+
+	c.Get("slow-T4")
+	report, err := l.Report()
+	// miniredis 不支援 SLOWLOG，仍返回客戶端的報告
+	if err == nil {
+		t.Errorf("Report() error = nil, want SLOWLOG error")
+	}
+	if len(report.Slow) == 0 || len(report.HotKeys) == 0 {
+		t.Errorf("Report() = %+v", report)
+	}
+}
+
+func TestCommandKey(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want string
+	}{
+		{[]interface{}{"get", "k"}, "k"},
+		{[]interface{}{"ping"}, ""},
+		{[]interface{}{"publish", "ch", "m"}, ""},
+		{[]interface{}{"evalsha", "sha", 1, "k1", "a"}, "k1"},
+		{[]interface{}{"eval", "return 1", 0}, ""},
+		{[]interface{}{"xread", "count", 10, "block", 0, "streams", "s1", "s2", "$", "$"}, "s1"},
+		{[]interface{}{"XREADGROUP", "GROUP", "g", "c", "STREAMS", "s1", ">"}, "s1"},
+	}
+	for _, tt := range tests {
+		if got := commandKey(tt.args); got != tt.want {
+			t.Errorf("commandKey(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,665 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/**
+Redis Stream 是 redis 5.0 新增的資料結構，是一個只能追加的日誌，
+搭配消費者群組(consumer group)可以讓多個消費者分攤處理訊息，並追蹤尚未確認(ack)的訊息。
+**/
+
+// XMessage stream 中的一筆訊息
+type XMessage struct {
+	ID     string
+	Values map[string]string
+}
+
+// XStream XREAD / XREADGROUP 回傳的單一 stream 訊息
+type XStream struct {
+	Stream   string
+	Messages []XMessage
+}
+
+// XPending XPENDING 的摘要
+type XPending struct {
+	Count     int64
+	Lower     string
+	Higher    string
+	Consumers map[string]int64
+}
+
+// XPendingEntry XPENDING 的明細
+type XPendingEntry struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration // 距離上次派送的時間
+	RetryCount int64         // 派送次數
+}
+
+// XInfoStream XINFO STREAM 的結果
+type XInfoStream struct {
+	Length          int64
+	RadixTreeKeys   int64
+	RadixTreeNodes  int64
+	Groups          int64
+	LastGeneratedID string
+	FirstEntry      *XMessage
+	LastEntry       *XMessage
+}
+
+// XInfoGroup XINFO GROUPS 的結果
+type XInfoGroup struct {
+	Name            string
+	Consumers       int64
+	Pending         int64
+	LastDeliveredID string
+}
+
+// XInfoConsumer XINFO CONSUMERS 的結果
+type XInfoConsumer struct {
+	Name    string
+	Pending int64
+	Idle    time.Duration
+}
+
+// XAddArgs XADD 參數
+type XAddArgs struct {
+	Stream     string
+	ID         string // 預設為 "*" 由 redis 產生
+	MaxLen     int64  // 新增後保留的最大長度，0 表示不修剪，不可與 MinID 同時設定
+	MinID      string // 新增後移除比 MinID 小的訊息(redis 6.2 以上)，不可與 MaxLen 同時設定
+	Approx     bool   // 使用 ~ 近似修剪，效能較好
+	NoMkStream bool   // stream 不存在時不建立(redis 6.2 以上)
+	Values     map[string]interface{}
+}
+
+// XReadArgs XREAD 參數
+type XReadArgs struct {
+	Streams []string      // stream 鍵名
+	IDs     []string      // 與 Streams 一一對應的起始id，未填時為 "$" (只讀新訊息)
+	Count   int64         // 每個 stream 最多讀幾筆，0 表示不限制
+	Block   time.Duration // 大於0時阻塞等待的時間，0 不阻塞，小於0無限期阻塞
+}
+
+// XReadGroupArgs XREADGROUP 參數
+type XReadGroupArgs struct {
+	Group    string
+	Consumer string
+	Streams  []string      // stream 鍵名
+	IDs      []string      // 與 Streams 一一對應的起始id，未填時為 ">" (只讀未派送過的訊息)
+	Count    int64         // 每個 stream 最多讀幾筆，0 表示不限制
+	Block    time.Duration // 大於0時阻塞等待的時間，0 不阻塞，小於0無限期阻塞
+	NoAck    bool          // 讀取後視為已確認，不放入 pending
+}
+
+// XPendingExtArgs XPENDING 明細參數
+type XPendingExtArgs struct {
+	Stream   string
+	Group    string
+	Idle     time.Duration // 只列出閒置超過此時間的訊息(redis 6.2 以上)
+	Start    string        // 預設 "-"
+	End      string        // 預設 "+"
+	Count    int64         // 預設 10
+	Consumer string        // 只列出該消費者的訊息
+}
+
+// XClaimArgs XCLAIM 參數
+type XClaimArgs struct {
+	Stream   string
+	Group    string
+	Consumer string
+	MinIdle  time.Duration // 只認領閒置超過此時間的訊息
+	IDs      []string
+}
+
+// XAutoClaimArgs XAUTOCLAIM 參數(redis 6.2 以上)
+type XAutoClaimArgs struct {
+	Stream   string
+	Group    string
+	Consumer string
+	MinIdle  time.Duration // 只認領閒置超過此時間的訊息
+	Start    string        // 預設 "0-0"
+	Count    int64         // 預設 100
+}
+
+// XAdd 新增訊息到 stream，返回訊息id。Values 的值與 Set 相同方式 encode。
+func (c *Cacher) XAdd(a *XAddArgs) *Cmd {
+	args := make([]interface{}, 0, 8+2*len(a.Values))
+	args = append(args, c.getKey(a.Stream))
+	if a.NoMkStream {
+		args = append(args, "NOMKSTREAM")
+	}
+	args, err := appendTrimArgs(args, a.MaxLen, a.MinID, a.Approx)
+	if err != nil {
+		return &Cmd{
+			Err: err,
+		}
+	}
+	if a.ID != "" {
+		args = append(args, a.ID)
+	} else {
+		args = append(args, "*")
+	}
+	for field, val := range a.Values {
+		value, err := c.encode(val)
+		if err != nil {
+			return &Cmd{
+				Err: err,
+			}
+		}
+		args = append(args, field, value)
+	}
+
+	return c.Do("XADD", args...)
+}
+
+// XLen 返回 stream 的訊息數量
+func (c *Cacher) XLen(stream string) *Cmd {
+	return c.Do("XLEN", c.getKey(stream))
+}
+
+// XDel 刪除 stream 中的訊息，返回刪除的數量
+func (c *Cacher) XDel(stream string, ids ...string) *Cmd {
+	args := make([]interface{}, 1, 1+len(ids))
+	args[0] = c.getKey(stream)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	return c.Do("XDEL", args...)
+}
+
+// XTrimMaxLen 修剪 stream 只保留最新的 maxLen 筆，返回移除的數量
+func (c *Cacher) XTrimMaxLen(stream string, maxLen int64, approx bool) *Cmd {
+	args, err := appendTrimArgs([]interface{}{c.getKey(stream)}, maxLen, "", approx)
+	if err != nil {
+		return &Cmd{
+			Err: err,
+		}
+	}
+	return c.Do("XTRIM", args...)
+}
+
+// XTrimMinID 修剪 stream 移除比 minID 小的訊息(redis 6.2 以上)，返回移除的數量
+func (c *Cacher) XTrimMinID(stream string, minID string, approx bool) *Cmd {
+	args, err := appendTrimArgs([]interface{}{c.getKey(stream)}, 0, minID, approx)
+	if err != nil {
+		return &Cmd{
+			Err: err,
+		}
+	}
+	return c.Do("XTRIM", args...)
+}
+
+// XRange 返回 id 在 start 到 stop 之間的訊息，"-" 和 "+" 分別表示最小及最大的id，count 為0時不限制數量
+func (c *Cacher) XRange(stream, start, stop string, count int64) ([]XMessage, error) {
+	args := []interface{}{c.getKey(stream), start, stop}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+
+	return parseXMessages(c.Do("XRANGE", args...).Value())
+}
+
+// XRevRange 與 XRange 相同，但由新到舊返回
+func (c *Cacher) XRevRange(stream, end, start string, count int64) ([]XMessage, error) {
+	args := []interface{}{c.getKey(stream), end, start}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+
+	return parseXMessages(c.Do("XREVRANGE", args...).Value())
+}
+
+// XRead 從一個或多個 stream 讀取訊息，阻塞超時返回 ErrNil
+func (c *Cacher) XRead(a *XReadArgs) ([]XStream, error) {
+	args := make([]interface{}, 0, 6+2*len(a.Streams))
+	if a.Count > 0 {
+		args = append(args, "COUNT", a.Count)
+	}
+	args = appendBlockArgs(args, a.Block)
+	args, err := c.appendStreamsArgs(args, a.Streams, a.IDs, "$")
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseXStreams(c.Do("XREAD", args...).Value())
+}
+
+// XReadGroup 以消費者群組讀取訊息，阻塞超時返回 ErrNil
+func (c *Cacher) XReadGroup(a *XReadGroupArgs) ([]XStream, error) {
+	args := make([]interface{}, 0, 10+2*len(a.Streams))
+	args = append(args, "GROUP", a.Group, a.Consumer)
+	if a.Count > 0 {
+		args = append(args, "COUNT", a.Count)
+	}
+	args = appendBlockArgs(args, a.Block)
+	if a.NoAck {
+		args = append(args, "NOACK")
+	}
+	args, err := c.appendStreamsArgs(args, a.Streams, a.IDs, ">")
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseXStreams(c.Do("XREADGROUP", args...).Value())
+}
+
+// XGroupCreate 建立消費者群組，start 為 "$" 表示只讀建立後的新訊息，"0" 表示從頭開始，mkStream 為 true 時 stream 不存在會自動建立
+func (c *Cacher) XGroupCreate(stream, group, start string, mkStream bool) *Cmd {
+	args := []interface{}{"CREATE", c.getKey(stream), group, start}
+	if mkStream {
+		args = append(args, "MKSTREAM")
+	}
+
+	return c.Do("XGROUP", args...)
+}
+
+// XGroupDestroy 刪除消費者群組
+func (c *Cacher) XGroupDestroy(stream, group string) *Cmd {
+	return c.Do("XGROUP", "DESTROY", c.getKey(stream), group)
+}
+
+// XGroupDelConsumer 從消費者群組移除消費者，返回該消費者尚未確認的訊息數量
+func (c *Cacher) XGroupDelConsumer(stream, group, consumer string) *Cmd {
+	return c.Do("XGROUP", "DELCONSUMER", c.getKey(stream), group, consumer)
+}
+
+// XAck 確認訊息已處理，返回確認的數量
+func (c *Cacher) XAck(stream, group string, ids ...string) *Cmd {
+	args := make([]interface{}, 2, 2+len(ids))
+	args[0] = c.getKey(stream)
+	args[1] = group
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	return c.Do("XACK", args...)
+}
+
+// XPending 返回消費者群組尚未確認訊息的摘要
+func (c *Cacher) XPending(stream, group string) (*XPending, error) {
+	values, err := c.Do("XPENDING", c.getKey(stream), group).Values()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("stream: unexpected number of values for XPENDING, got %d", len(values))
+	}
+
+	pending := &XPending{
+		Consumers: make(map[string]int64),
+	}
+	if pending.Count, err = Int64(values[0], nil); err != nil {
+		return nil, err
+	}
+	if pending.Count == 0 {
+		return pending, nil
+	}
+	if pending.Lower, err = String(values[1], nil); err != nil {
+		return nil, err
+	}
+	if pending.Higher, err = String(values[2], nil); err != nil {
+		return nil, err
+	}
+	consumers, err := Values(values[3], nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, consumer := range consumers {
+		pair, err := Strings(consumer, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(pair) != 2 {
+			return nil, errors.New("stream: unexpected XPENDING consumer reply")
+		}
+		count, err := Int64(pair[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		pending.Consumers[pair[0]] = count
+	}
+
+	return pending, nil
+}
+
+// XPendingExt 返回尚未確認訊息的明細
+func (c *Cacher) XPendingExt(a *XPendingExtArgs) ([]XPendingEntry, error) {
+	args := []interface{}{c.getKey(a.Stream), a.Group}
+	if a.Idle > 0 {
+		args = append(args, "IDLE", int64(a.Idle/time.Millisecond))
+	}
+	start, end, count := a.Start, a.End, a.Count
+	if start == "" {
+		start = "-"
+	}
+	if end == "" {
+		end = "+"
+	}
+	if count <= 0 {
+		count = 10
+	}
+	args = append(args, start, end, count)
+	if a.Consumer != "" {
+		args = append(args, a.Consumer)
+	}
+
+	values, err := c.Do("XPENDING", args...).Values()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]XPendingEntry, 0, len(values))
+	for _, v := range values {
+		fields, err := Values(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != 4 {
+			return nil, errors.New("stream: unexpected XPENDING entry reply")
+		}
+		entry := XPendingEntry{}
+		if entry.ID, err = String(fields[0], nil); err != nil {
+			return nil, err
+		}
+		if entry.Consumer, err = String(fields[1], nil); err != nil {
+			return nil, err
+		}
+		idle, err := Int64(fields[2], nil)
+		if err != nil {
+			return nil, err
+		}
+		entry.Idle = time.Duration(idle) * time.Millisecond
+		if entry.RetryCount, err = Int64(fields[3], nil); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// XClaim 將閒置超過 MinIdle 的訊息轉給指定的消費者，返回認領到的訊息
+func (c *Cacher) XClaim(a *XClaimArgs) ([]XMessage, error) {
+	args := make([]interface{}, 0, 4+len(a.IDs))
+	args = append(args, c.getKey(a.Stream), a.Group, a.Consumer, int64(a.MinIdle/time.Millisecond))
+	for _, id := range a.IDs {
+		args = append(args, id)
+	}
+
+	return parseXMessages(c.Do("XCLAIM", args...).Value())
+}
+
+// XAutoClaim 從 Start 開始掃描，將閒置超過 MinIdle 的訊息轉給指定的消費者(redis 6.2 以上)。
+// 返回認領到的訊息以及下一次掃描的起始id，起始id為 "0-0" 表示已掃描完畢。
+func (c *Cacher) XAutoClaim(a *XAutoClaimArgs) ([]XMessage, string, error) {
+	start, count := a.Start, a.Count
+	if start == "" {
+		start = "0-0"
+	}
+	if count <= 0 {
+		count = 100
+	}
+
+	values, err := c.Do("XAUTOCLAIM", c.getKey(a.Stream), a.Group, a.Consumer,
+		int64(a.MinIdle/time.Millisecond), start, "COUNT", count).Values()
+	if err != nil {
+		return nil, "", err
+	}
+	// redis 7.0 起多回傳一個已刪除的id列表
+	if len(values) < 2 {
+		return nil, "", fmt.Errorf("stream: unexpected number of values for XAUTOCLAIM, got %d", len(values))
+	}
+	next, err := String(values[0], nil)
+	if err != nil {
+		return nil, "", err
+	}
+	msgs, err := parseXMessages(values[1], nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return msgs, next, nil
+}
+
+// XInfoStream 返回 stream 的資訊
+func (c *Cacher) XInfoStream(stream string) (*XInfoStream, error) {
+	m, err := parseFlatMap(c.Do("XINFO", "STREAM", c.getKey(stream)).Value())
+	if err != nil {
+		return nil, err
+	}
+
+	info := &XInfoStream{}
+	info.Length, _ = Int64(m["length"], nil)
+	info.RadixTreeKeys, _ = Int64(m["radix-tree-keys"], nil)
+	info.RadixTreeNodes, _ = Int64(m["radix-tree-nodes"], nil)
+	info.Groups, _ = Int64(m["groups"], nil)
+	info.LastGeneratedID, _ = String(m["last-generated-id"], nil)
+	if v, ok := m["first-entry"]; ok && v != nil {
+		if info.FirstEntry, err = parseXMessage(v); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := m["last-entry"]; ok && v != nil {
+		if info.LastEntry, err = parseXMessage(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+// XInfoGroups 返回 stream 的所有消費者群組資訊
+func (c *Cacher) XInfoGroups(stream string) ([]XInfoGroup, error) {
+	values, err := c.Do("XINFO", "GROUPS", c.getKey(stream)).Values()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]XInfoGroup, 0, len(values))
+	for _, v := range values {
+		m, err := parseFlatMap(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		group := XInfoGroup{}
+		group.Name, _ = String(m["name"], nil)
+		group.Consumers, _ = Int64(m["consumers"], nil)
+		group.Pending, _ = Int64(m["pending"], nil)
+		group.LastDeliveredID, _ = String(m["last-delivered-id"], nil)
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// XInfoConsumers 返回消費者群組內的所有消費者資訊
+func (c *Cacher) XInfoConsumers(stream, group string) ([]XInfoConsumer, error) {
+	values, err := c.Do("XINFO", "CONSUMERS", c.getKey(stream), group).Values()
+	if err != nil {
+		return nil, err
+	}
+
+	consumers := make([]XInfoConsumer, 0, len(values))
+	for _, v := range values {
+		m, err := parseFlatMap(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		consumer := XInfoConsumer{}
+		consumer.Name, _ = String(m["name"], nil)
+		consumer.Pending, _ = Int64(m["pending"], nil)
+		idle, _ := Int64(m["idle"], nil)
+		consumer.Idle = time.Duration(idle) * time.Millisecond
+		consumers = append(consumers, consumer)
+	}
+
+	return consumers, nil
+}
+
+// appendStreamsArgs 加上 STREAMS key... id...，鍵名會加上前綴
+func (c *Cacher) appendStreamsArgs(args []interface{}, streams, ids []string, defaultID string) ([]interface{}, error) {
+	if len(streams) == 0 {
+		return nil, errors.New("stream: missing streams")
+	}
+	if len(ids) != 0 && len(ids) != len(streams) {
+		return nil, errors.New("stream: streams and ids must have the same length")
+	}
+
+	args = append(args, "STREAMS")
+	for _, stream := range streams {
+		args = append(args, c.getKey(stream))
+	}
+	for i := range streams {
+		if len(ids) == 0 {
+			args = append(args, defaultID)
+		} else {
+			args = append(args, ids[i])
+		}
+	}
+
+	return args, nil
+}
+
+// parseXStreams 解析 XREAD / XREADGROUP 的回傳值，stream 名稱會去掉前綴
+func (c *Cacher) parseXStreams(reply interface{}, err error) ([]XStream, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	streams := make([]XStream, 0, len(values))
+	for _, v := range values {
+		pair, err := Values(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(pair) != 2 {
+			return nil, errors.New("stream: unexpected stream reply")
+		}
+		name, err := String(pair[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := parseXMessages(pair[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		streams = append(streams, XStream{
+			Stream:   c.trimKey(name),
+			Messages: msgs,
+		})
+	}
+
+	return streams, nil
+}
+
+// appendTrimArgs 加上 MAXLEN / MINID 修剪參數，兩者同時設定時返回錯誤
+func appendTrimArgs(args []interface{}, maxLen int64, minID string, approx bool) ([]interface{}, error) {
+	switch {
+	case maxLen > 0 && minID != "":
+		return nil, errors.New("stream: MaxLen and MinID are mutually exclusive")
+	case maxLen > 0:
+		args = append(args, "MAXLEN")
+		if approx {
+			args = append(args, "~")
+		}
+		args = append(args, maxLen)
+	case minID != "":
+		args = append(args, "MINID")
+		if approx {
+			args = append(args, "~")
+		}
+		args = append(args, minID)
+	}
+
+	return args, nil
+}
+
+// appendBlockArgs 加上 BLOCK 參數
+func appendBlockArgs(args []interface{}, block time.Duration) []interface{} {
+	switch {
+	case block > 0:
+		ms := int64(block / time.Millisecond)
+		if ms < 1 {
+			ms = 1
+		}
+		args = append(args, "BLOCK", ms)
+	case block < 0:
+		args = append(args, "BLOCK", 0)
+	}
+
+	return args
+}
+
+// parseXMessages 解析訊息列表
+func parseXMessages(reply interface{}, err error) ([]XMessage, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]XMessage, 0, len(values))
+	for _, v := range values {
+		msg, err := parseXMessage(v)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, *msg)
+	}
+
+	return msgs, nil
+}
+
+// parseXMessage 解析單筆訊息 [id, [field, value, ...]]，已被刪除的訊息 Values 為空
+func parseXMessage(reply interface{}) (*XMessage, error) {
+	pair, err := Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(pair) != 2 {
+		return nil, errors.New("stream: unexpected stream message reply")
+	}
+	id, err := String(pair[0], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &XMessage{
+		ID:     id,
+		Values: map[string]string{},
+	}
+	if pair[1] == nil {
+		return msg, nil
+	}
+	if msg.Values, err = StringMap(pair[1], nil); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// parseFlatMap 解析 [key, value, key, value...] 形式的回傳值
+func parseFlatMap(reply interface{}, err error) (map[string]interface{}, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("stream: flat map expects even number of values result")
+	}
+
+	m := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, err := String(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = values[i+1]
+	}
+
+	return m, nil
+}
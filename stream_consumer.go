@@ -0,0 +1,296 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamHandler stream 訊息處理函式，回傳 nil 時訊息會被 XACK，否則留在 pending 等待重新派送
+type StreamHandler func(ctx context.Context, msg XMessage) error
+
+// ConsumeOption Consume 的設定
+type ConsumeOption func(*consumeOptions)
+
+type consumeOptions struct {
+	batchSize     int64
+	block         time.Duration
+	claimInterval time.Duration
+	claimMinIdle  time.Duration
+	maxDeliveries int64
+	deadLetter    string
+	onError       func(err error)
+}
+
+// WithBatchSize 每次 XREADGROUP 讀取的數量，預設10
+func WithBatchSize(n int64) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.batchSize = n
+	}
+}
+
+// WithBlock XREADGROUP 阻塞等待的時間，預設1秒，需小於 ReadTimeout
+func WithBlock(block time.Duration) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.block = block
+	}
+}
+
+// WithClaim 每隔 interval 以 XAUTOCLAIM 認領閒置超過 minIdle 的訊息(例如已崩潰的消費者)，預設30秒與1分鐘
+func WithClaim(interval, minIdle time.Duration) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.claimInterval = interval
+		o.claimMinIdle = minIdle
+	}
+}
+
+// WithDeadLetter 派送次數超過 maxDeliveries 的訊息會被移到 stream 這個死信 stream，預設5次及 "<stream>:dead"
+func WithDeadLetter(stream string, maxDeliveries int64) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.deadLetter = stream
+		o.maxDeliveries = maxDeliveries
+	}
+}
+
+// WithConsumeError 處理函式或 redis 發生錯誤時的回呼
+func WithConsumeError(fn func(err error)) ConsumeOption {
+	return func(o *consumeOptions) {
+		o.onError = fn
+	}
+}
+
+type streamConsumer struct {
+	cacher   *Cacher
+	stream   string
+	group    string
+	consumer string
+	handler  StreamHandler
+	opts     consumeOptions
+}
+
+// Consume 以消費者群組持續處理 stream 訊息，群組不存在時會自動建立。
+// 啟動時會先處理該消費者上次未確認的訊息，之後以 XREADGROUP 阻塞批次讀取；
+// 並定期以 XAUTOCLAIM 認領其他消費者閒置過久的訊息；重新派送的訊息派送次數過多時會移到死信 stream。
+// ctx 結束或 GracefulStop 時，會等目前的訊息處理完才返回 nil。
+func (c *Cacher) Consume(ctx context.Context, stream, group, consumer string, handler StreamHandler, opts ...ConsumeOption) error {
+	if handler == nil {
+		panic("nil handler")
+	}
+	o := consumeOptions{
+		batchSize:     10,
+		block:         time.Second,
+		claimInterval: 30 * time.Second,
+		claimMinIdle:  time.Minute,
+		maxDeliveries: 5,
+		deadLetter:    stream + ":dead",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	err := c.XGroupCreate(stream, group, "0", true).Err
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopped := make(chan struct{})
+	unregister := c.onStop("stream consumer "+stream+"/"+group+"/"+consumer, func() {
+		cancel()
+		<-stopped
+	})
+	defer func() {
+		unregister()
+		close(stopped)
+	}()
+
+	sc := &streamConsumer{
+		cacher:   c,
+		stream:   stream,
+		group:    group,
+		consumer: consumer,
+		handler:  handler,
+		opts:     o,
+	}
+	sc.run(loopCtx, ctx)
+
+	return nil
+}
+
+// run 讀取迴圈直到 loopCtx 結束，handlerCtx 為傳給處理函式的 context
+func (sc *streamConsumer) run(loopCtx, handlerCtx context.Context) {
+	// 先讀自己尚未確認的訊息，讀完後才改讀新訊息
+	lastID := "0"
+	nextClaim := time.Now()
+
+	for loopCtx.Err() == nil {
+		if !time.Now().Before(nextClaim) {
+			sc.claim(loopCtx, handlerCtx)
+			nextClaim = time.Now().Add(sc.opts.claimInterval)
+		}
+
+		block := sc.opts.block
+		if lastID != ">" {
+			block = 0
+		}
+		streams, err := sc.cacher.XReadGroup(&XReadGroupArgs{
+			Group:    sc.group,
+			Consumer: sc.consumer,
+			Streams:  []string{sc.stream},
+			IDs:      []string{lastID},
+			Count:    sc.opts.batchSize,
+			Block:    block,
+		})
+		if err != nil && err != ErrNil {
+			sc.reportError(err)
+			sleepContext(loopCtx, time.Second)
+			continue
+		}
+
+		var msgs []XMessage
+		if len(streams) > 0 {
+			msgs = streams[0].Messages
+		}
+		if lastID != ">" && len(msgs) == 0 {
+			lastID = ">"
+			continue
+		}
+		for _, msg := range msgs {
+			if lastID == ">" {
+				sc.handle(handlerCtx, msg)
+				continue
+			}
+			lastID = msg.ID
+			sc.redeliver(handlerCtx, msg)
+		}
+	}
+}
+
+// claim 認領閒置過久的訊息並處理，派送次數過多的移到死信 stream
+func (sc *streamConsumer) claim(loopCtx, handlerCtx context.Context) {
+	start := "0-0"
+	for loopCtx.Err() == nil {
+		msgs, next, err := sc.cacher.XAutoClaim(&XAutoClaimArgs{
+			Stream:   sc.stream,
+			Group:    sc.group,
+			Consumer: sc.consumer,
+			MinIdle:  sc.opts.claimMinIdle,
+			Start:    start,
+			Count:    sc.opts.batchSize,
+		})
+		if err != nil {
+			sc.reportError(err)
+			return
+		}
+
+		for _, msg := range msgs {
+			sc.redeliver(handlerCtx, msg)
+		}
+
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// redeliver 處理重新派送的 pending 訊息，派送次數過多的移到死信 stream
+func (sc *streamConsumer) redeliver(ctx context.Context, msg XMessage) {
+	if sc.opts.maxDeliveries > 0 {
+		deliveries, err := sc.deliveries(msg.ID)
+		if err != nil {
+			sc.reportError(err)
+			return
+		}
+		if deliveries > sc.opts.maxDeliveries {
+			sc.deadLetter(msg, deliveries)
+			return
+		}
+	}
+	sc.handle(ctx, msg)
+}
+
+// handle 處理單筆訊息，成功時 XACK
+func (sc *streamConsumer) handle(ctx context.Context, msg XMessage) {
+	// 已被 XDEL 的訊息沒有內容，直接確認
+	if len(msg.Values) != 0 {
+		if err := sc.call(ctx, msg); err != nil {
+			sc.reportError(err)
+			return
+		}
+	}
+	if err := sc.cacher.XAck(sc.stream, sc.group, msg.ID).Err; err != nil {
+		sc.reportError(err)
+	}
+}
+
+// call 執行處理函式，panic 視為失敗
+func (sc *streamConsumer) call(ctx context.Context, msg XMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("stream: panic: %v", r)
+		}
+	}()
+
+	return sc.handler(ctx, msg)
+}
+
+// deliveries 取得訊息的派送次數
+func (sc *streamConsumer) deliveries(id string) (int64, error) {
+	entries, err := sc.cacher.XPendingExt(&XPendingExtArgs{
+		Stream:   sc.stream,
+		Group:    sc.group,
+		Start:    id,
+		End:      id,
+		Count:    1,
+		Consumer: sc.consumer,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	return entries[0].RetryCount, nil
+}
+
+// deadLetter 將訊息連同來源資訊移到死信 stream 並確認原訊息
+func (sc *streamConsumer) deadLetter(msg XMessage, deliveries int64) {
+	values := make(map[string]interface{}, len(msg.Values)+4)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["_stream"] = sc.stream
+	values["_group"] = sc.group
+	values["_id"] = msg.ID
+	values["_deliveries"] = strconv.FormatInt(deliveries, 10)
+
+	if err := sc.cacher.XAdd(&XAddArgs{Stream: sc.opts.deadLetter, Values: values}).Err; err != nil {
+		sc.reportError(err)
+		return
+	}
+	if err := sc.cacher.XAck(sc.stream, sc.group, msg.ID).Err; err != nil {
+		sc.reportError(err)
+	}
+}
+
+func (sc *streamConsumer) reportError(err error) {
+	if sc.opts.onError != nil {
+		sc.opts.onError(err)
+	}
+}
+
+// sleepContext 等待 d 或 ctx 結束
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,215 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+func TestCacher_Consume(t *testing.T) {
+	c, s := newTestCacher(t)
+	stream := "consume-T1"
+
+	var mu sync.Mutex
+	var got []string
+	done := make(chan error)
+	go func() {
+		done <- c.Consume(context.Background(), stream, "group", "consumer-1",
+			func(ctx context.Context, msg XMessage) error {
+				mu.Lock()
+				got = append(got, msg.Values["n"])
+				mu.Unlock()
+				return nil
+			},
+			WithBlock(10*time.Millisecond),
+			WithClaim(time.Hour, time.Hour),
+		)
+	}()
+
+	// 等群組建立後才寫入
+	waitFor(time.Second, func() bool { return s.Exists(c.getKey(stream)) })
+	c.XAdd(&XAddArgs{Stream: stream, Values: map[string]interface{}{"n": "a"}})
+
+	waitFor(time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 1
+	})
+
+	c.GracefulStop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Consume() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Consume() did not return after GracefulStop")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0] != "a" {
+		t.Errorf("handled = %v, want [a]", got)
+	}
+}
+
+func TestStreamConsumer_Pending(t *testing.T) {
+	c, _ := newTestCacher(t)
+	stream := "consume-T2"
+	c.XGroupCreate(stream, "group", "0", true)
+
+	// consumer-1 上次讀到但尚未確認的訊息
+	c.XAdd(&XAddArgs{Stream: stream, Values: map[string]interface{}{"n": "pending"}})
+	c.XReadGroup(&XReadGroupArgs{Group: "group", Consumer: "consumer-1", Streams: []string{stream}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+	sc := &streamConsumer{
+		cacher:   c,
+		stream:   stream,
+		group:    "group",
+		consumer: "consumer-1",
+		handler: func(ctx context.Context, msg XMessage) error {
+			got = append(got, msg.Values["n"])
+			cancel()
+			return nil
+		},
+		opts: consumeOptions{batchSize: 10, block: 10 * time.Millisecond, claimInterval: time.Hour},
+	}
+	sc.run(ctx, ctx)
+
+	if len(got) != 1 || got[0] != "pending" {
+		t.Errorf("handled = %v, want [pending]", got)
+	}
+}
+
+func TestStreamConsumer_PendingDeadLetter(t *testing.T) {
+	c, s := newTestCacher(t)
+	stream := "consume-T5"
+	c.XGroupCreate(stream, "group", "0", true)
+	c.XAdd(&XAddArgs{Stream: stream, Values: map[string]interface{}{"n": "poison"}})
+	streams, err := c.XReadGroup(&XReadGroupArgs{Group: "group", Consumer: "consumer-1", Streams: []string{stream}})
+	if err != nil {
+		t.Fatalf("XReadGroup() error = %v", err)
+	}
+	id := streams[0].Messages[0].ID
+
+	// miniredis 不支援 XPENDING，回覆已派送6次
+	s.Server().SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+		if !strings.EqualFold(cmd, "XPENDING") {
+			return false
+		}
+		c.WriteLen(1)
+		c.WriteLen(4)
+		c.WriteBulk(id)
+		c.WriteBulk("consumer-1")
+		c.WriteInt(0)
+		c.WriteInt(6)
+		return true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var handled int32
+	sc := &streamConsumer{
+		cacher:   c,
+		stream:   stream,
+		group:    "group",
+		consumer: "consumer-1",
+		handler: func(ctx context.Context, msg XMessage) error {
+			atomic.StoreInt32(&handled, 1)
+			return errors.New("failed")
+		},
+		opts: consumeOptions{
+			batchSize:     10,
+			block:         10 * time.Millisecond,
+			claimInterval: time.Hour,
+			maxDeliveries: 5,
+			deadLetter:    stream + ":dead",
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		sc.run(ctx, ctx)
+		close(done)
+	}()
+
+	waitFor(time.Second, func() bool {
+		msgs, _ := c.XRange(stream+":dead", "-", "+", 0)
+		return len(msgs) == 1
+	})
+	cancel()
+	<-done
+
+	msgs, err := c.XRange(stream+":dead", "-", "+", 0)
+	if err != nil {
+		t.Fatalf("XRange() error = %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Values["_id"] != id {
+		t.Errorf("dead letters = %v, want %s", msgs, id)
+	}
+	if atomic.LoadInt32(&handled) != 0 {
+		t.Error("handler called for message over MaxDeliveries")
+	}
+}
+
+func TestCacher_ConsumeContext(t *testing.T) {
+	c, _ := newTestCacher(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var reported int32
+	done := make(chan error)
+	go func() {
+		done <- c.Consume(ctx, "consume-T3", "group", "consumer-1",
+			func(ctx context.Context, msg XMessage) error {
+				return errors.New("failed")
+			},
+			WithBlock(10*time.Millisecond),
+			WithConsumeError(func(err error) { atomic.StoreInt32(&reported, 1) }),
+		)
+	}()
+	c.XAdd(&XAddArgs{Stream: "consume-T3", Values: map[string]interface{}{"n": 1}})
+
+	waitFor(time.Second, func() bool { return atomic.LoadInt32(&reported) == 1 })
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Consume() error = %v", err)
+	}
+}
+
+func TestStreamConsumer_DeadLetter(t *testing.T) {
+	c, _ := newTestCacher(t)
+	stream := "consume-T4"
+	c.XGroupCreate(stream, "group", "0", true)
+	c.XAdd(&XAddArgs{Stream: stream, Values: map[string]interface{}{"n": "poison"}})
+	streams, err := c.XReadGroup(&XReadGroupArgs{Group: "group", Consumer: "consumer-1", Streams: []string{stream}})
+	if err != nil {
+		t.Fatalf("XReadGroup() error = %v", err)
+	}
+
+	sc := &streamConsumer{
+		cacher: c,
+		stream: stream,
+		group:  "group",
+		opts:   consumeOptions{deadLetter: stream + ":dead"},
+	}
+	sc.deadLetter(streams[0].Messages[0], 6)
+
+	msgs, err := c.XRange(stream+":dead", "-", "+", 0)
+	if err != nil {
+		t.Fatalf("XRange() error = %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("dead letter len = %d, want 1", len(msgs))
+	}
+	v := msgs[0].Values
+	if v["n"] != "poison" || v["_id"] != streams[0].Messages[0].ID || v["_deliveries"] != "6" {
+		t.Errorf("dead letter values = %v", v)
+	}
+}
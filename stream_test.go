@@ -0,0 +1,157 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCacher_XAddXRange(t *testing.T) {
+	c := redisCacher
+	stream := "stream-T1"
+
+	for i := 0; i < 3; i++ {
+		cmd := c.XAdd(&XAddArgs{
+			Stream: stream,
+			MaxLen: 2,
+			Values: map[string]interface{}{"n": i},
+		})
+		if cmd.Err != nil {
+			t.Fatalf("XAdd() error = %v", cmd.Err)
+		}
+	}
+	if n, _ := c.XLen(stream).Int(); n != 2 {
+		t.Errorf("XLen() = %d, want 2", n)
+	}
+
+	msgs, err := c.XRange(stream, "-", "+", 0)
+	if err != nil {
+		t.Fatalf("XRange() error = %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].Values["n"] != "1" || msgs[1].Values["n"] != "2" {
+		t.Errorf("XRange() = %+v", msgs)
+	}
+
+	msgs, err = c.XRevRange(stream, "+", "-", 1)
+	if err != nil {
+		t.Fatalf("XRevRange() error = %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Values["n"] != "2" {
+		t.Errorf("XRevRange() = %+v", msgs)
+	}
+
+	if n, _ := c.XDel(stream, msgs[0].ID).Int(); n != 1 {
+		t.Errorf("XDel() = %d, want 1", n)
+	}
+	info, err := c.XInfoStream(stream)
+	if err != nil {
+		t.Fatalf("XInfoStream() error = %v", err)
+	}
+	if info.Length != 1 {
+		t.Errorf("XInfoStream() length = %d, want 1", info.Length)
+	}
+}
+
+func TestCacher_XAddTrimConflict(t *testing.T) {
+	c := redisCacher
+	cmd := c.XAdd(&XAddArgs{
+		Stream: "stream-T6",
+		MaxLen: 10,
+		MinID:  "1-0",
+		Values: map[string]interface{}{"a": "1"},
+	})
+	if cmd.Err == nil || cmd.Err.Error() != "stream: MaxLen and MinID are mutually exclusive" {
+		t.Errorf("XAdd() error = %v, want mutually exclusive", cmd.Err)
+	}
+	if n, _ := c.XLen("stream-T6").Int(); n != 0 {
+		t.Errorf("XLen() = %d, want 0", n)
+	}
+}
+
+func TestCacher_XRead(t *testing.T) {
+	c := redisCacher
+	c.XAdd(&XAddArgs{Stream: "stream-T2", ID: "1-0", Values: map[string]interface{}{"a": "1"}})
+
+	streams, err := c.XRead(&XReadArgs{
+		Streams: []string{"stream-T2"},
+		IDs:     []string{"0"},
+	})
+	if err != nil {
+		t.Fatalf("XRead() error = %v", err)
+	}
+	want := []XStream{{
+		Stream:   "stream-T2",
+		Messages: []XMessage{{ID: "1-0", Values: map[string]string{"a": "1"}}},
+	}}
+	if !reflect.DeepEqual(streams, want) {
+		t.Errorf("XRead() = %+v, want %+v", streams, want)
+	}
+
+	if _, err := c.XRead(&XReadArgs{Streams: []string{"stream-T2"}, IDs: []string{"0", "0"}}); err == nil {
+		t.Errorf("XRead() mismatched ids error = nil")
+	}
+}
+
+func TestCacher_XReadGroup(t *testing.T) {
+	c := redisCacher
+	stream := "stream-T3"
+
+	if err := c.XGroupCreate(stream, "group", "0", true).Err; err != nil {
+		t.Fatalf("XGroupCreate() error = %v", err)
+	}
+	c.XAdd(&XAddArgs{Stream: stream, Values: map[string]interface{}{"order": 1}})
+
+	streams, err := c.XReadGroup(&XReadGroupArgs{
+		Group:    "group",
+		Consumer: "consumer-1",
+		Streams:  []string{stream},
+		Count:    10,
+	})
+	if err != nil {
+		t.Fatalf("XReadGroup() error = %v", err)
+	}
+	if len(streams) != 1 || len(streams[0].Messages) != 1 {
+		t.Fatalf("XReadGroup() = %+v", streams)
+	}
+	if streams[0].Stream != stream {
+		t.Errorf("XReadGroup() stream = %s, want %s", streams[0].Stream, stream)
+	}
+
+	if n, _ := c.XAck(stream, "group", streams[0].Messages[0].ID).Int(); n != 1 {
+		t.Errorf("XAck() = %d, want 1", n)
+	}
+}
+
+func TestParseXMessages(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{"1-0", []interface{}{"k", "v"}},
+		[]interface{}{"2-0", nil},
+	}
+	msgs, err := parseXMessages(reply, nil)
+	if err != nil {
+		t.Fatalf("parseXMessages() error = %v", err)
+	}
+	want := []XMessage{
+		{ID: "1-0", Values: map[string]string{"k": "v"}},
+		{ID: "2-0", Values: map[string]string{}},
+	}
+	if !reflect.DeepEqual(msgs, want) {
+		t.Errorf("parseXMessages() = %+v, want %+v", msgs, want)
+	}
+
+	if _, err := parseXMessages(nil, nil); err != ErrNil {
+		t.Errorf("parseXMessages(nil) error = %v, want %v", err, ErrNil)
+	}
+}
+
+func TestParseFlatMap(t *testing.T) {
+	m, err := parseFlatMap([]interface{}{"name", "group", "pending", int64(2)}, nil)
+	if err != nil {
+		t.Fatalf("parseFlatMap() error = %v", err)
+	}
+	if m["name"] != "group" || m["pending"] != int64(2) {
+		t.Errorf("parseFlatMap() = %v", m)
+	}
+	if _, err := parseFlatMap([]interface{}{"name"}, nil); err == nil {
+		t.Errorf("parseFlatMap() odd values error = nil")
+	}
+}
@@ -0,0 +1,117 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// TimeoutOptions 以 time.Duration 設定的逾時，可精確到毫秒以下
+type TimeoutOptions struct {
+	Dial  time.Duration // 建立連接的逾時，設定時取代 Options.DialTimeout
+	Read  time.Duration // socket 讀取逾時，設定時取代 Options.ReadTimeout
+	Write time.Duration // socket 寫入逾時，設定時取代 Options.WriteTimeout
+	Pool  time.Duration // 等待連接池的逾時，設定時取代 Options.PoolTimeout
+	// Default 每個指令的預設逾時(包含等待連接池)，0 表示只受 socket 逾時限制
+	Default time.Duration
+	// Commands 依指令名稱(大寫)設定的逾時，優先於 Default，例如 {"GET": 5 * time.Millisecond}
+	Commands map[string]time.Duration
+}
+
+// WithTimeout 產生指令逾時為 d 的 Cacher，優先於 Options.Timeouts 的設定。
+// 阻塞指令(BLPOP、XREAD BLOCK 等)的逾時會自動加上阻塞時間，無限期阻塞時不套用 Timeouts 的設定，只套用 WithTimeout
+func (c *Cacher) WithTimeout(d time.Duration) *Cacher {
+	clone := c.clone()
+	clone.timeout = d
+	return clone
+}
+
+// commandTimeout 指令的逾時，依序為 WithTimeout、Commands、Default
+func (c *Cacher) commandTimeout(name string) time.Duration {
+	if c.timeout > 0 {
+		return c.timeout
+	}
+	if c.timeouts == nil {
+		return 0
+	}
+	if d, ok := c.timeouts.Commands[strings.ToUpper(name)]; ok {
+		return d
+	}
+	return c.timeouts.Default
+}
+
+// commandClient 依指令的逾時設定 ctx 的 deadline，阻塞指令另外延長 client 的 socket 讀取逾時，返回的 cancel 需在指令結束後呼叫
+func (c *Cacher) commandClient(ctx context.Context, client *redis.Client, args []interface{}) (context.Context, *redis.Client, context.CancelFunc) {
+	name := formatArg(args[0])
+	timeout := c.commandTimeout(name)
+
+	block, blocking := blockDuration(args)
+	if blocking {
+		readTimeout := client.Options().ReadTimeout
+		switch {
+		case block == 0:
+			// 無限期阻塞，只保留 WithTimeout 明確設定的逾時
+			readTimeout = 0
+			if c.timeout <= 0 {
+				timeout = 0
+			}
+		case readTimeout > 0:
+			readTimeout += block
+		}
+		if readTimeout != client.Options().ReadTimeout {
+			// go-redis 的 WithTimeout 會一併改寫入逾時，改回原本的設定；返回的 client 有自己的 Options 副本
+			writeTimeout := client.Options().WriteTimeout
+			client = client.WithTimeout(readTimeout)
+			client.Options().WriteTimeout = writeTimeout
+		}
+		if timeout > 0 {
+			timeout += block
+		}
+	}
+
+	if timeout <= 0 {
+		return ctx, client, func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, client, cancel
+}
+
+// blockDuration 取得阻塞指令的阻塞時間，0 表示無限期阻塞，不是阻塞指令時返回 false
+func blockDuration(args []interface{}) (time.Duration, bool) {
+	name := strings.ToUpper(formatArg(args[0]))
+	switch name {
+	case "BLPOP", "BRPOP", "BRPOPLPUSH", "BLMOVE", "BZPOPMIN", "BZPOPMAX":
+		// 最後一個參數為秒數，可以是小數
+		seconds, err := strconv.ParseFloat(formatArg(args[len(args)-1]), 64)
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	case "XREAD", "XREADGROUP":
+		for i := 1; i < len(args)-1; i++ {
+			arg := formatArg(args[i])
+			if strings.EqualFold(arg, "STREAMS") {
+				break
+			}
+			if strings.EqualFold(arg, "BLOCK") {
+				ms, err := strconv.ParseInt(formatArg(args[i+1]), 10, 64)
+				if err != nil {
+					return 0, false
+				}
+				return time.Duration(ms) * time.Millisecond, true
+			}
+		}
+	case "WAIT":
+		if len(args) == 3 {
+			ms, err := strconv.ParseInt(formatArg(args[2]), 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return time.Duration(ms) * time.Millisecond, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacher_WithTimeout(t *testing.T) {
+	c, _ := newTestCacher(t)
+
+	if err := c.WithTimeout(time.Nanosecond).Get("timeout-T1").Err; err == nil || err == ErrNil {
+		t.Errorf("Get() error = %v, want deadline error", err)
+	}
+	// 阻塞時間會加到逾時上
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		c.RPush("timeout-list", "v")
+	}()
+	if got, err := c.WithTimeout(5*time.Millisecond).BLPop("timeout-list", 1).String(); err != nil || got != "v" {
+		t.Errorf("BLPop() = %q, %v, want v", got, err)
+	}
+	if err := c.WithTimeout(time.Second).Set("timeout-T1", "a", 0).Err; err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+	if c.timeout != 0 {
+		t.Errorf("WithTimeout() modified the original Cacher")
+	}
+}
+
+func TestCacher_CommandTimeouts(t *testing.T) {
+	c, s := newTestCacher(t, func(o *Options) {
+		o.Timeouts = TimeoutOptions{
+			Read:     50 * time.Millisecond,
+			Default:  time.Second,
+			Commands: map[string]time.Duration{"get": time.Millisecond},
+		}
+	})
+	if got := c.commandTimeout("GET"); got != time.Millisecond {
+		t.Errorf("commandTimeout(GET) = %v, want 1ms", got)
+	}
+	if got := c.commandTimeout("SET"); got != time.Second {
+		t.Errorf("commandTimeout(SET) = %v, want 1s", got)
+	}
+	if got := c.WithTimeout(time.Minute).commandTimeout("GET"); got != time.Minute {
+		t.Errorf("WithTimeout().commandTimeout(GET) = %v, want 1m", got)
+	}
+
+	// 阻塞時間超過 socket 讀取逾時仍可等到結果
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		s.Lpush("RedisTest:timeout-list", "v")
+	}()
+	if got, err := c.BLPop("timeout-list", 1).String(); err != nil || got != "v" {
+		t.Errorf("BLPop() = %q, %v, want v", got, err)
+	}
+}
+
+func TestCacher_CommandClient(t *testing.T) {
+	c, _ := newTestCacher(t, func(o *Options) {
+		o.Timeouts = TimeoutOptions{
+			Read:    50 * time.Millisecond,
+			Write:   20 * time.Millisecond,
+			Default: time.Second,
+		}
+	})
+	tests := []struct {
+		name         string
+		cacher       *Cacher
+		args         []interface{}
+		deadline     time.Duration // 0 表示沒有 deadline
+		readTimeout  time.Duration
+		writeTimeout time.Duration
+	}{
+		{"block", c, []interface{}{"BLPOP", "k", 1}, 2 * time.Second, 1050 * time.Millisecond, 20 * time.Millisecond},
+		{"forever", c, []interface{}{"BLPOP", "k", 0}, 0, 0, 20 * time.Millisecond},
+		// 無限期阻塞仍保留 WithTimeout 明確設定的逾時
+		{"forever WithTimeout", c.WithTimeout(100 * time.Millisecond), []interface{}{"BLPOP", "k", 0}, 100 * time.Millisecond, 0, 20 * time.Millisecond},
+		{"get", c, []interface{}{"GET", "k"}, time.Second, 50 * time.Millisecond, 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, client, cancel := tt.cacher.commandClient(context.Background(), tt.cacher.pool, tt.args)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			switch {
+			case tt.deadline == 0 && ok:
+				t.Errorf("deadline = %v, want none", deadline)
+			case tt.deadline > 0 && (!ok || time.Until(deadline) > tt.deadline || time.Until(deadline) < tt.deadline-100*time.Millisecond):
+				t.Errorf("deadline in %v, want about %v", time.Until(deadline), tt.deadline)
+			}
+			if got := client.Options().ReadTimeout; got != tt.readTimeout {
+				t.Errorf("ReadTimeout = %v, want %v", got, tt.readTimeout)
+			}
+			if got := client.Options().WriteTimeout; got != tt.writeTimeout {
+				t.Errorf("WriteTimeout = %v, want %v", got, tt.writeTimeout)
+			}
+		})
+	}
+	if got := c.pool.Options().WriteTimeout; got != 20*time.Millisecond {
+		t.Errorf("pool WriteTimeout = %v, want 20ms", got)
+	}
+}
+
+func TestBlockDuration(t *testing.T) {
+	tests := []struct {
+		args     []interface{}
+		want     time.Duration
+		blocking bool
+	}{
+		{[]interface{}{"GET", "k"}, 0, false},
+		{[]interface{}{"BLPOP", "a", "b", 2}, 2 * time.Second, true},
+		{[]interface{}{"brpoplpush", "a", "b", "0.5"}, 500 * time.Millisecond, true},
+		{[]interface{}{"BZPOPMIN", "z", 0}, 0, true},
+		{[]interface{}{"XREAD", "COUNT", 1, "BLOCK", 100, "STREAMS", "s", "$"}, 100 * time.Millisecond, true},
+		{[]interface{}{"XREAD", "STREAMS", "BLOCK", "0"}, 0, false},
+		{[]interface{}{"XREADGROUP", "GROUP", "g", "c", "BLOCK", 0, "STREAMS", "s", ">"}, 0, true},
+		{[]interface{}{"WAIT", 1, 300}, 300 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		got, blocking := blockDuration(tt.args)
+		if got != tt.want || blocking != tt.blocking {
+			t.Errorf("blockDuration(%v) = %v, %v, want %v, %v", tt.args, got, blocking, tt.want, tt.blocking)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StatementMode db.statement 記錄的內容
+type StatementMode int
+
+const (
+	// StatementRedacted 指令及第一個參數(通常是鍵名)，其餘參數以 ? 代替(預設)
+	StatementRedacted StatementMode = iota
+	// StatementFull 完整的指令及參數
+	StatementFull
+	// StatementCommand 只有指令名稱
+	StatementCommand
+)
+
+// TracingOptions OpenTelemetry 追蹤設定
+type TracingOptions struct {
+	TracerProvider  trace.TracerProvider            // 預設 otel.GetTracerProvider()
+	Statement       StatementMode                   // db.statement 記錄的內容
+	Redact          func(args []interface{}) string // 自訂 db.statement 的內容，優先於 Statement
+	MaxStatementLen int                             // db.statement 的最大長度，預設256
+}
+
+// EnableTracing 為每個指令、pipeline、腳本及鎖操作建立 span，父 span 取自 WithContext 傳入的 context。
+// 需在 WithContext 及開始執行指令之前呼叫(見 AddHook)，之前產生的 Cacher 只會有指令層級的 span。
+func (c *Cacher) EnableTracing(opts TracingOptions) {
+	if opts.TracerProvider == nil {
+		opts.TracerProvider = otel.GetTracerProvider()
+	}
+	if opts.MaxStatementLen <= 0 {
+		opts.MaxStatementLen = 256
+	}
+
+	h := &tracingHook{
+		tracer: opts.TracerProvider.Tracer("jim352261/repackageredis"),
+		opts:   opts,
+		attrs: []label.KeyValue{
+			label.String("db.system", "redis"),
+			label.Int("db.redis.database_index", c.pool.Options().DB),
+			label.String("db.redis.key_prefix", c.prefix),
+		},
+	}
+	c.tracing = h
+	c.addRedisHook(h)
+}
+
+// startSpan 開啟操作層級的 span(腳本、鎖)，返回帶有該 span 的 Cacher 及結束 span 的函式，未啟用追蹤時返回原本的 Cacher
+func (c *Cacher) startSpan(name string, attrs ...label.KeyValue) (*Cacher, func(err error)) {
+	if c.tracing == nil {
+		return c, func(error) {}
+	}
+
+	ctx, span := c.tracing.tracer.Start(c.context(), name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, c.tracing.attrs...)...),
+	)
+	clone := c.clone()
+	clone.ctx.Context = ctx
+
+	return clone, func(err error) {
+		recordSpanError(span, err)
+		span.End()
+	}
+}
+
+// context 取得 WithContext 傳入的 context，沒有時返回 context.Background()
+func (c *Cacher) context() context.Context {
+	if c.ctx.Context != nil {
+		return c.ctx.Context
+	}
+	return context.Background()
+}
+
+// tracingHook 以 go-redis 的 hook 為每個指令建立 span
+type tracingHook struct {
+	tracer trace.Tracer
+	opts   TracingOptions
+	attrs  []label.KeyValue
+}
+
+func (h *tracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = h.tracer.Start(ctx, strings.ToUpper(cmd.Name()),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(h.attrs, label.String("db.statement", h.statement(cmd.Args())))...),
+	)
+
+	return ctx, nil
+}
+
+func (h *tracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	span := trace.SpanFromContext(ctx)
+	recordSpanError(span, cmd.Err())
+	span.End()
+
+	return nil
+}
+
+func (h *tracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	statements := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		statements[i] = h.statement(cmd.Args())
+	}
+	ctx, _ = h.tracer.Start(ctx, "PIPELINE",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(h.attrs,
+			label.String("db.statement", strings.Join(statements, "\n")),
+			label.Int("db.redis.num_cmd", len(cmds)),
+		)...),
+	)
+
+	return ctx, nil
+}
+
+func (h *tracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	span := trace.SpanFromContext(ctx)
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && err != redis.Nil {
+			recordSpanError(span, err)
+			break
+		}
+	}
+	span.End()
+
+	return nil
+}
+
+func (h *tracingHook) statement(args []interface{}) string {
+	if h.opts.Redact != nil {
+		return h.opts.Redact(args)
+	}
+	return commandStatement(args, h.opts.Statement, h.opts.MaxStatementLen)
+}
+
+// commandStatement 將指令格式化成字串，依 mode 遮蔽參數，超過 maxLen 時截斷
+func commandStatement(args []interface{}, mode StatementMode, maxLen int) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	name := strings.ToUpper(formatArg(args[0]))
+	parts := make([]string, 0, len(args))
+	parts = append(parts, name)
+	for i, arg := range args[1:] {
+		switch {
+		case mode == StatementCommand:
+		case mode == StatementFull:
+			parts = append(parts, formatArg(arg))
+		// EVAL 的第一個參數是腳本內容
+		case i == 0 && name != "EVAL":
+			parts = append(parts, formatArg(arg))
+		default:
+			parts = append(parts, "?")
+		}
+	}
+
+	s := strings.Join(parts, " ")
+	if maxLen > 0 && len(s) > maxLen {
+		s = s[:maxLen] + "..."
+	}
+
+	return s
+}
+
+// recordSpanError 記錄錯誤，redis nil 不視為錯誤
+func recordSpanError(span trace.Span, err error) {
+	if err == nil || err == redis.Nil || err == ErrNil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
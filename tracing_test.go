@@ -0,0 +1,193 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordTracer 記錄建立的 span，用來驗證追蹤
+type recordTracer struct {
+	mu    sync.Mutex
+	spans []*recordSpan
+}
+
+func (t *recordTracer) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return t
+}
+
+func (t *recordTracer) Start(ctx context.Context, name string, opts ...trace.SpanOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanConfig(opts...)
+	span := &recordSpan{tracer: t, name: name, attrs: make(map[label.Key]string)}
+	if parent, ok := trace.SpanFromContext(ctx).(*recordSpan); ok {
+		span.parent = parent
+	}
+	span.SetAttributes(cfg.Attributes...)
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// find 取得指定名稱的 span
+func (t *recordTracer) find(name string) *recordSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, span := range t.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+type recordSpan struct {
+	tracer *recordTracer
+	name   string
+	parent *recordSpan
+
+	mu     sync.Mutex
+	attrs  map[label.Key]string
+	status codes.Code
+	ended  bool
+}
+
+func (s *recordSpan) Tracer() trace.Tracer                             { return s.tracer }
+func (s *recordSpan) AddEvent(name string, opts ...trace.EventOption)  {}
+func (s *recordSpan) IsRecording() bool                                { return true }
+func (s *recordSpan) RecordError(err error, opts ...trace.EventOption) {}
+func (s *recordSpan) SpanContext() trace.SpanContext                   { return trace.SpanContext{} }
+func (s *recordSpan) SetName(name string)                              { s.name = name }
+
+func (s *recordSpan) End(opts ...trace.SpanOption) {
+	s.mu.Lock()
+	s.ended = true
+	s.mu.Unlock()
+}
+
+func (s *recordSpan) SetStatus(code codes.Code, msg string) {
+	s.mu.Lock()
+	s.status = code
+	s.mu.Unlock()
+}
+
+func (s *recordSpan) SetAttributes(kv ...label.KeyValue) {
+	s.mu.Lock()
+	for _, attr := range kv {
+		s.attrs[attr.Key] = attr.Value.Emit()
+	}
+	s.mu.Unlock()
+}
+
+func TestCacher_EnableTracing(t *testing.T) {
+	c, _ := newTestCacher(t)
+	tracer := &recordTracer{}
+	c.EnableTracing(TracingOptions{TracerProvider: tracer})
+
+	c.Set("trace-T1", "secret", 0)
+	span := tracer.find("SET")
+	if span == nil {
+		t.Fatalf("SET span not found")
+	}
+	want := map[label.Key]string{
+		"db.system":               "redis",
+		"db.statement":            "SET RedisTest:trace-T1 ?",
+		"db.redis.key_prefix":     "RedisTest:",
+		"db.redis.database_index": "0",
+	}
+	for k, v := range want {
+		if span.attrs[k] != v {
+			t.Errorf("attribute %s = %q, want %q", k, span.attrs[k], v)
+		}
+	}
+	if !span.ended || span.status != codes.Unset {
+		t.Errorf("span ended = %v, status = %v", span.ended, span.status)
+	}
+
+	c.Do("INCR", c.getKey("trace-T1"))
+	if span := tracer.find("INCR"); span == nil || span.status != codes.Error {
+		t.Errorf("INCR span = %+v, want error status", span)
+	}
+}
+
+func TestCacher_TracingParent(t *testing.T) {
+	c, _ := newTestCacher(t)
+	tracer := &recordTracer{}
+	c.EnableTracing(TracingOptions{TracerProvider: tracer})
+
+	ctx, root := tracer.Start(context.Background(), "request")
+	cc := c.WithContext(ctx, "traceID")
+
+	NewScript(1, "return redis.call('GET', KEYS[1])").DoScript(cc, cc.getKey("trace-T2"))
+	script := tracer.find("redis.script")
+	eval := tracer.find("EVAL")
+	if script == nil || script.parent != root {
+		t.Fatalf("script span = %+v, want child of request", script)
+	}
+	if eval == nil || eval.parent != script {
+		t.Errorf("EVAL span = %+v, want child of redis.script", eval)
+	}
+	if eval.attrs["db.statement"] != "EVAL ? ? ?" {
+		t.Errorf("EVAL db.statement = %q", eval.attrs["db.statement"])
+	}
+
+	m := cc.NewMutex("trace-T2-lock")
+	if err := m.Lock(); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	m.UnLock()
+	lock := tracer.find("redis.lock")
+	if lock == nil || lock.parent != root || lock.attrs["db.redis.lock"] != "trace-T2-lock" {
+		t.Errorf("lock span = %+v", lock)
+	}
+	if set := tracer.find("SET"); set == nil || set.parent != lock {
+		t.Errorf("SET span = %+v, want child of redis.lock", set)
+	}
+}
+
+func TestCommandStatement(t *testing.T) {
+	args := []interface{}{"set", "key", "value", "EX", 10}
+	tests := []struct {
+		name   string
+		args   []interface{}
+		mode   StatementMode
+		maxLen int
+		want   string
+	}{
+		{name: "redacted", args: args, mode: StatementRedacted, want: "SET key ? ? ?"},
+		{name: "full", args: args, mode: StatementFull, want: "SET key value EX 10"},
+		{name: "command", args: args, mode: StatementCommand, want: "SET"},
+		{name: "truncate", args: args, mode: StatementFull, maxLen: 9, want: "SET key v..."},
+		{name: "eval", args: []interface{}{"eval", "return 1", 0}, mode: StatementRedacted, want: "EVAL ? ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commandStatement(tt.args, tt.mode, tt.maxLen); got != tt.want {
+				t.Errorf("commandStatement() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacher_TracingRedact(t *testing.T) {
+	c, _ := newTestCacher(t)
+	tracer := &recordTracer{}
+	c.EnableTracing(TracingOptions{
+		TracerProvider: tracer,
+		Redact: func(args []interface{}) string {
+			return "custom"
+		},
+	})
+
+	c.Get("trace-T3")
+	if span := tracer.find("GET"); span == nil || span.attrs["db.statement"] != "custom" {
+		t.Errorf("GET span = %+v", span)
+	}
+}
@@ -0,0 +1,351 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWorkerPoolStarted WorkerPool 已經啟動
+var ErrWorkerPoolStarted = errors.New("worker: pool already started")
+
+// WorkerHandler 工作處理函式，回傳錯誤時會依設定延遲重試，超過重試次數後移到死信列表
+type WorkerHandler func(ctx context.Context, job *Job) error
+
+// WorkerOptions WorkerPool 設定
+type WorkerOptions struct {
+	Concurrency       int           // 每個佇列同時處理的數量，預設1
+	MaxRetries        int           // 失敗後最多重試幾次，預設3，小於0表示不重試
+	RetryBackoff      time.Duration // 第一次重試的等待時間，之後每次加倍，預設1秒
+	MaxRetryBackoff   time.Duration // 重試等待時間上限，預設10分鐘
+	VisibilityTimeout time.Duration // 工作處理超過此時間會被視為卡住並重新派送，預設30秒
+	PollTimeout       int           // 取工作時阻塞的秒數，預設1秒，也是停止時等待取工作的最長時間
+	PollInterval      time.Duration // 延遲工作搬移與卡住工作回收的間隔，預設1秒
+	Consumer          string        // 消費者名稱前綴，預設為 hostname-pid
+	ShutdownTimeout   time.Duration // GracefulStop 時等待處理中工作的時間，超過時取消處理函式的 context，預設30秒
+	// OnError 處理、重試及移到死信列表的錯誤，未設定時寫到 Logger
+	OnError func(queue string, job *Job, err error)
+}
+
+// QueueStats 佇列統計
+type QueueStats struct {
+	Pending   int64 // 等待處理
+	Delayed   int64 // 等待重試或尚未到期
+	InFlight  int64 // 處理中
+	Failed    int64 // 死信列表中的數量
+	Processed int64 // 本實例處理成功的數量
+	Retried   int64 // 本實例重試的次數
+}
+
+// WorkerPool 工作處理框架，每個具名佇列註冊一個處理函式，並以指定的併發數處理
+type WorkerPool struct {
+	cacher *Cacher
+	opts   WorkerOptions
+
+	mu      sync.Mutex
+	queues  map[string]*workerQueue
+	started bool
+
+	wg        sync.WaitGroup
+	quit      chan struct{}
+	stopOnce  sync.Once
+	bgCtx     context.Context
+	bgCancel  context.CancelFunc
+	jobCtx    context.Context
+	jobCancel context.CancelFunc
+}
+
+type workerQueue struct {
+	name        string
+	queue       *ReliableQueue
+	delayed     *DelayedQueue
+	handler     WorkerHandler
+	concurrency int
+	processed   int64
+	retried     int64
+}
+
+// NewWorkerPool 產生新的 WorkerPool
+func (c *Cacher) NewWorkerPool(opts WorkerOptions) *WorkerPool {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = time.Second
+	}
+	if opts.MaxRetryBackoff <= 0 {
+		opts.MaxRetryBackoff = 10 * time.Minute
+	}
+	if opts.PollTimeout <= 0 {
+		opts.PollTimeout = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = 30 * time.Second
+	}
+	if opts.Consumer == "" {
+		hostname, _ := os.Hostname()
+		opts.Consumer = hostname + "-" + strconv.Itoa(os.Getpid())
+	}
+
+	p := &WorkerPool{
+		cacher: c,
+		opts:   opts,
+		queues: make(map[string]*workerQueue),
+		quit:   make(chan struct{}),
+	}
+	p.bgCtx, p.bgCancel = context.WithCancel(context.Background())
+	p.jobCtx, p.jobCancel = context.WithCancel(context.Background())
+
+	return p
+}
+
+// Handle 註冊佇列的處理函式，concurrency 小於等於0時使用 WorkerOptions.Concurrency，
+// 需在 Start 之前呼叫，之後呼叫返回 ErrWorkerPoolStarted
+func (p *WorkerPool) Handle(queue string, concurrency int, handler WorkerHandler) error {
+	if handler == nil {
+		panic("nil handler")
+	}
+	if concurrency <= 0 {
+		concurrency = p.opts.Concurrency
+	}
+
+	wq := p.queue(queue)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return ErrWorkerPoolStarted
+	}
+	wq.handler = handler
+	wq.concurrency = concurrency
+
+	return nil
+}
+
+// Enqueue 將工作加入佇列
+func (p *WorkerPool) Enqueue(queue string, payload interface{}) *Cmd {
+	return p.queue(queue).queue.Push(payload)
+}
+
+// EnqueueAfter 將工作在 delay 時間後加入佇列
+func (p *WorkerPool) EnqueueAfter(queue string, delay time.Duration, payload interface{}) *Cmd {
+	return p.queue(queue).delayed.PushAfter(delay, payload)
+}
+
+// Queue 取得佇列，可用來查詢死信列表(DeadLetters)等
+func (p *WorkerPool) Queue(queue string) *ReliableQueue {
+	return p.queue(queue).queue
+}
+
+// Start 啟動所有已註冊的佇列，GracefulStop 時會自動呼叫 Shutdown，最多等待 ShutdownTimeout
+func (p *WorkerPool) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return ErrWorkerPoolStarted
+	}
+	p.started = true
+
+	for _, wq := range p.queues {
+		if wq.handler == nil {
+			continue
+		}
+		go wq.delayed.Run(p.bgCtx)
+		go wq.queue.RunReaper(p.bgCtx, p.opts.PollInterval)
+		for i := 0; i < wq.concurrency; i++ {
+			p.wg.Add(1)
+			go p.work(wq, p.opts.Consumer+"-"+strconv.Itoa(i))
+		}
+	}
+	p.cacher.onStop("worker pool", func() {
+		// 超過 ShutdownTimeout 時取消處理函式的 context 並返回，不等卡住的處理函式
+		ctx, cancel := context.WithTimeout(context.Background(), p.opts.ShutdownTimeout)
+		defer cancel()
+		p.Shutdown(ctx)
+	})
+
+	return nil
+}
+
+// Shutdown 停止取新的工作，並等待處理中的工作結束。
+// ctx 結束時會取消傳給處理函式的 context 並返回 ctx.Err()，未完成的工作會在可見時間後重新派送。
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		close(p.quit)
+		p.bgCancel()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.jobCancel()
+		return ctx.Err()
+	}
+}
+
+// Stats 取得佇列統計
+func (p *WorkerPool) Stats(queue string) (*QueueStats, error) {
+	wq := p.queue(queue)
+	q := wq.queue
+	stats := &QueueStats{
+		Processed: atomic.LoadInt64(&wq.processed),
+		Retried:   atomic.LoadInt64(&wq.retried),
+	}
+
+	var err error
+	if stats.Pending, err = q.Len().Int64(); err != nil {
+		return nil, err
+	}
+	if stats.Delayed, err = wq.delayed.Len().Int64(); err != nil {
+		return nil, err
+	}
+	if stats.InFlight, err = q.cacher.ZCard(q.inflightKey()).Int64(); err != nil {
+		return nil, err
+	}
+	if stats.Failed, err = q.cacher.LLen(q.deadKey()).Int64(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// queue 取得或建立佇列
+func (p *WorkerPool) queue(name string) *workerQueue {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	wq, ok := p.queues[name]
+	if !ok {
+		wq = &workerQueue{name: name}
+		q := p.cacher.NewReliableQueue(name, ReliableQueueOptions{
+			VisibilityTimeout: p.opts.VisibilityTimeout,
+			OnReapError: func(err error) {
+				p.reportError(wq, nil, err)
+			},
+		})
+		wq.queue = q
+		wq.delayed = p.cacher.NewDelayedQueue(q, DelayedQueueOptions{
+			PollInterval: p.opts.PollInterval,
+			OnError: func(err error) {
+				p.reportError(wq, nil, err)
+			},
+		})
+		p.queues[name] = wq
+	}
+
+	return wq
+}
+
+func (p *WorkerPool) work(wq *workerQueue, consumer string) {
+	defer p.wg.Done()
+
+	// 同名消費者上次未完成的工作
+	if _, err := wq.queue.Recover(consumer); err != nil {
+		p.reportError(wq, nil, err)
+	}
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		default:
+		}
+
+		job, err := wq.queue.Pop(consumer, p.opts.PollTimeout)
+		if err == ErrNil {
+			continue
+		}
+		if err != nil {
+			p.reportError(wq, nil, err)
+			select {
+			case <-p.quit:
+				return
+			case <-time.After(p.opts.PollInterval):
+			}
+			continue
+		}
+
+		p.process(wq, job)
+	}
+}
+
+func (p *WorkerPool) process(wq *workerQueue, job *Job) {
+	err := p.call(wq, job)
+	if err == nil {
+		atomic.AddInt64(&wq.processed, 1)
+		if err := wq.queue.Ack(job); err != nil {
+			p.reportError(wq, job, err)
+		}
+		return
+	}
+	p.reportError(wq, job, err)
+
+	if p.opts.MaxRetries > 0 && job.Attempts < p.opts.MaxRetries {
+		atomic.AddInt64(&wq.retried, 1)
+		if err := wq.delayed.Retry(job, p.backoff(job.Attempts), err); err != nil {
+			p.reportError(wq, job, err)
+		}
+		return
+	}
+	if err := wq.queue.Fail(job, err); err != nil {
+		p.reportError(wq, job, err)
+	}
+}
+
+// call 執行處理函式，panic 視為失敗
+func (p *WorkerPool) call(wq *workerQueue, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("worker: panic: %v", r)
+		}
+	}()
+
+	return wq.handler(p.jobCtx, job)
+}
+
+// backoff 第 attempts 次失敗後的等待時間
+func (p *WorkerPool) backoff(attempts int) time.Duration {
+	d := p.opts.RetryBackoff
+	for i := 0; i < attempts; i++ {
+		d *= 2
+		if d >= p.opts.MaxRetryBackoff {
+			return p.opts.MaxRetryBackoff
+		}
+	}
+
+	return d
+}
+
+// reportError 交給 OnError，未設定時寫到 Logger
+func (p *WorkerPool) reportError(wq *workerQueue, job *Job, err error) {
+	if p.opts.OnError != nil {
+		p.opts.OnError(wq.name, job, err)
+		return
+	}
+	if logger := p.cacher.getLogger(); logger != nil {
+		keysAndValues := []interface{}{"queue", wq.name, "error", err}
+		if job != nil {
+			keysAndValues = append(keysAndValues, "job", job.ID)
+		}
+		logger.Error("worker job failed", keysAndValues...)
+	}
+}
@@ -0,0 +1,168 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor 等待條件成立，逾時返回 false
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestWorkerPool_Process(t *testing.T) {
+	c, _ := newTestCacher(t)
+	p := c.NewWorkerPool(WorkerOptions{
+		Concurrency:  2,
+		PollInterval: 5 * time.Millisecond,
+	})
+
+	var sum int64
+	p.Handle("worker-T1", 0, func(ctx context.Context, job *Job) error {
+		var n int64
+		if err := job.Scan(&n); err != nil {
+			return err
+		}
+		atomic.AddInt64(&sum, n)
+		return nil
+	})
+	for i := 1; i <= 10; i++ {
+		p.Enqueue("worker-T1", i)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := p.Start(); err != ErrWorkerPoolStarted {
+		t.Errorf("Start() twice error = %v, want %v", err, ErrWorkerPoolStarted)
+	}
+	if err := p.Handle("worker-T1b", 0, func(ctx context.Context, job *Job) error { return nil }); err != ErrWorkerPoolStarted {
+		t.Errorf("Handle() after Start error = %v, want %v", err, ErrWorkerPoolStarted)
+	}
+
+	if !waitFor(2*time.Second, func() bool { return atomic.LoadInt64(&sum) == 55 }) {
+		t.Errorf("sum = %d, want 55", atomic.LoadInt64(&sum))
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	stats, err := p.Stats("worker-T1")
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.Processed != 10 || stats.Pending != 0 || stats.InFlight != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestWorkerPool_DeadLetter(t *testing.T) {
+	c, _ := newTestCacher(t)
+	p := c.NewWorkerPool(WorkerOptions{
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	})
+
+	var calls int64
+	p.Handle("worker-T2", 1, func(ctx context.Context, job *Job) error {
+		atomic.AddInt64(&calls, 1)
+		return errors.New("boom")
+	})
+	p.Enqueue("worker-T2", "payload")
+	p.Start()
+	defer p.Shutdown(context.Background())
+
+	ok := waitFor(3*time.Second, func() bool {
+		stats, err := p.Stats("worker-T2")
+		return err == nil && stats.Failed == 1
+	})
+	if !ok {
+		t.Fatalf("job not moved to dead letter, calls = %d", atomic.LoadInt64(&calls))
+	}
+	if n := atomic.LoadInt64(&calls); n != 3 {
+		t.Errorf("calls = %d, want 3", n)
+	}
+
+	jobs, err := p.Queue("worker-T2").DeadLetters(0, -1)
+	if err != nil {
+		t.Fatalf("DeadLetters() error = %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].LastError != "boom" || jobs[0].Attempts != 3 {
+		t.Errorf("DeadLetters() = %+v", jobs)
+	}
+}
+
+func TestWorkerPool_GracefulStop(t *testing.T) {
+	c, _ := newTestCacher(t)
+	p := c.NewWorkerPool(WorkerOptions{PollInterval: 5 * time.Millisecond})
+
+	started := make(chan struct{})
+	var finished int64
+	p.Handle("worker-T3", 1, func(ctx context.Context, job *Job) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt64(&finished, 1)
+		return nil
+	})
+	p.Enqueue("worker-T3", "slow")
+	p.Start()
+
+	<-started
+	c.GracefulStop()
+	if atomic.LoadInt64(&finished) != 1 {
+		t.Errorf("GracefulStop() returned before in-flight job finished")
+	}
+}
+
+func TestWorkerPool_ShutdownTimeout(t *testing.T) {
+	c, _ := newTestCacher(t)
+	logger := &recordLogger{}
+	c.SetLogger(logger)
+	p := c.NewWorkerPool(WorkerOptions{
+		MaxRetries:      -1,
+		PollInterval:    5 * time.Millisecond,
+		ShutdownTimeout: 50 * time.Millisecond,
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	p.Handle("worker-T4", 1, func(ctx context.Context, job *Job) error {
+		if job.Body == "fail" {
+			return errors.New("boom")
+		}
+		close(started)
+		<-release // 不理會 ctx 的處理函式
+		return nil
+	})
+	p.Enqueue("worker-T4", "fail")
+	p.Start()
+
+	// 沒有 OnError 時寫到 Logger
+	if !waitFor(time.Second, func() bool { return logger.has("worker job failed") }) {
+		t.Errorf("handler error not logged")
+	}
+
+	p.Enqueue("worker-T4", "hang")
+	<-started
+	done := make(chan struct{})
+	go func() {
+		c.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GracefulStop() blocked by hung handler")
+	}
+}